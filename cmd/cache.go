@@ -0,0 +1,104 @@
+// Copyright © 2018 Steve Huff <steve.huff@asics.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/asicsdigital/masonjar/jar"
+	"github.com/spf13/afero"
+)
+
+// stageCache copies the cached clone of a repository to a new directory
+// beside it, so that the copy can be updated without touching the original
+// until swapCache is called.
+func stageCache(dir string) (string, error) {
+	staged, err := ioutil.TempDir(filepath.Dir(dir), filepath.Base(dir)+".update-")
+
+	if err != nil {
+		return "", err
+	}
+
+	srcFs := afero.NewBasePathFs(afero.NewOsFs(), dir)
+	destFs := afero.NewBasePathFs(afero.NewOsFs(), staged)
+
+	err = afero.Walk(srcFs, "/", func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			return destFs.MkdirAll(path, info.Mode())
+		}
+
+		return jar.CopyFile(path, path, srcFs, destFs)
+	})
+
+	if err != nil {
+		os.RemoveAll(staged)
+		return "", err
+	}
+
+	return staged, nil
+}
+
+// newCacheDir returns an empty directory beside dir in which to clone a
+// repository.
+func newCacheDir(dir string) (string, error) {
+	err := os.MkdirAll(filepath.Dir(dir), 0700)
+
+	if err != nil {
+		return "", err
+	}
+
+	return ioutil.TempDir(filepath.Dir(dir), filepath.Base(dir)+".clone-")
+}
+
+// swapCache replaces the cached clone in dir, if there is one, with the
+// clone in staged.  If the swap fails, the original clone is put back, and if
+// that fails too, the error says where the original clone was left.
+func swapCache(staged string, dir string) error {
+	old := staged + ".old"
+	err := os.Rename(dir, old)
+
+	if os.IsNotExist(err) {
+		return os.Rename(staged, dir)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(staged, dir)
+
+	if err != nil {
+		if restoreErr := os.Rename(old, dir); restoreErr != nil {
+			return fmt.Errorf("unable to replace %v: %v; the previous clone could not be restored from %v: %v", dir, err, old, restoreErr)
+		}
+
+		return err
+	}
+
+	return os.RemoveAll(old)
+}
@@ -0,0 +1,217 @@
+// Copyright © 2018 Steve Huff <steve.huff@asics.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/asicsdigital/masonjar/jar"
+	"github.com/spf13/cobra"
+	jww "github.com/spf13/jwalterweatherman"
+	"github.com/spf13/viper"
+)
+
+// lintCmd represents the lint command
+var lintCmd = &cobra.Command{
+	Use:   "lint [jar...]",
+	Short: "Check jars for mistakes",
+	Long: `Check jars for mistakes before they are used.
+
+Every jar is checked, or just the jars named on the command line.  Use --dir
+to check the jars in a local directory, e.g. a checkout of a jar repository,
+rather than the jars downloaded by "masonjar update".
+
+Each jar's metadata must parse and contain only the keys masonjar knows
+about.  Every file listed under "templates" must exist, and templates must
+parse and use only declared variables or values with a default.  Hooks must
+run commands which can be found, symlinks must not point outside the jar,
+and every path must render, without colliding with another, using the
+default of each variable or an example value.
+
+Problems are reported as errors or warnings.  Use --output json or --output
+yaml for output which scripts can read.  masonjar exits with a non-zero
+status if any errors were found, or with --strict, any warnings.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		jww.DEBUG.Println("lint called")
+
+		format := viper.GetString("LintOutput")
+		err := checkOutputFormat(format, "text", "json", "yaml")
+
+		if err != nil {
+			jww.ERROR.Println(err)
+			os.Exit(1)
+		}
+
+		var jars []jar.Jar
+		var invalid []jar.InvalidJar
+		var repos []jar.Repository
+
+		if dir := viper.GetString("LintDir"); len(dir) > 0 {
+			jars, invalid, err = jar.ParseDirectory(dir)
+		} else {
+			repos, err = configuredRepositories()
+
+			if err != nil {
+				jww.ERROR.Println(err)
+				os.Exit(1)
+			}
+
+			jars, invalid, err = jar.ParseCatalog(repos)
+		}
+
+		if jars == nil && invalid == nil && err != nil {
+			jww.ERROR.Println(err)
+			os.Exit(1)
+		}
+
+		if len(args) > 0 {
+			jars, invalid, err = selectLintJars(args, jars, invalid, repos)
+
+			if err != nil {
+				jww.ERROR.Println(err)
+				os.Exit(1)
+			}
+		}
+
+		report := lintReport{Jars: len(jars) + len(invalid), Findings: []jar.Finding{}}
+
+		for i := range invalid {
+			report.add(invalid[i].Finding())
+		}
+
+		for i := range jars {
+			jww.INFO.Printf("linting jar %v", jar.QualifiedName(jars[i]))
+
+			for _, f := range jar.Lint(jars[i]) {
+				report.add(f)
+			}
+		}
+
+		if format == "text" {
+			printFindings(report)
+		} else if err := writeOutput(format, report); err != nil {
+			jww.ERROR.Println(err)
+			os.Exit(1)
+		}
+
+		if report.failed(viper.GetBool("LintStrict")) {
+			os.Exit(1)
+		}
+	},
+}
+
+// lintReport is the output of "masonjar lint".
+type lintReport struct {
+	Jars     int           `json:"jars" yaml:"jars"`
+	Errors   int           `json:"errors" yaml:"errors"`
+	Warnings int           `json:"warnings" yaml:"warnings"`
+	Findings []jar.Finding `json:"findings" yaml:"findings"`
+}
+
+func (r *lintReport) add(f jar.Finding) {
+	if f.Severity == jar.SeverityError {
+		r.Errors++
+	} else {
+		r.Warnings++
+	}
+
+	r.Findings = append(r.Findings, f)
+}
+
+// failed reports whether lint should exit with a non-zero status: if there
+// are any errors, or with strict, any warnings.
+func (r *lintReport) failed(strict bool) bool {
+	return r.Errors > 0 || (strict && r.Warnings > 0)
+}
+
+func init() {
+	rootCmd.AddCommand(lintCmd)
+
+	// Here you will define your flags and configuration settings.
+
+	// Cobra supports Persistent Flags which will work for this command
+	// and all subcommands, e.g.:
+	// lintCmd.PersistentFlags().String("foo", "", "A help for foo")
+
+	// Cobra supports local flags which will only run when this command
+	// is called directly, e.g.:
+	// lintCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	lintCmd.Flags().StringP("output", "o", "text", "Report problems as text, json or yaml")
+	viper.BindPFlag("LintOutput", lintCmd.Flags().Lookup("output"))
+
+	lintCmd.Flags().String("dir", "", "Check the jars in this directory instead of the configured repositories")
+	viper.BindPFlag("LintDir", lintCmd.Flags().Lookup("dir"))
+
+	lintCmd.Flags().Bool("strict", false, "Exit with a non-zero status if there are any warnings")
+	viper.BindPFlag("LintStrict", lintCmd.Flags().Lookup("strict"))
+}
+
+// selectLintJars returns the named jars, including those which could not be
+// parsed.  Every name must match a jar.
+func selectLintJars(names []string, jars []jar.Jar, invalid []jar.InvalidJar, repos []jar.Repository) ([]jar.Jar, []jar.InvalidJar, error) {
+	var selectedInvalid []jar.InvalidJar
+	var remaining []string
+
+	for _, name := range names {
+		found := false
+
+		for _, j := range invalid {
+			if j.Name == name || strings.TrimPrefix(j.Name, j.Repository+"/") == name {
+				selectedInvalid = append(selectedInvalid, j)
+				found = true
+			}
+		}
+
+		if !found {
+			remaining = append(remaining, name)
+		}
+	}
+
+	selected, missing, err := jar.FindJars(remaining, jars, repos)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(missing) > 0 {
+		return nil, nil, fmt.Errorf("Unable to find a jar matching '%v'.  Use `masonjar list` to list available jars.", strings.Join(missing, "', '"))
+	}
+
+	return selected, selectedInvalid, nil
+}
+
+// printFindings prints each finding on a line of its own, like a compiler,
+// followed by a summary.
+func printFindings(report lintReport) {
+	for _, f := range report.Findings {
+		location := f.Jar
+
+		if len(f.Path) > 0 {
+			location = fmt.Sprintf("%v: %v", f.Jar, f.Path)
+		}
+
+		fmt.Printf("%v: %v: %v [%v]\n", location, f.Severity, f.Message, f.Check)
+	}
+
+	fmt.Fprintf(os.Stderr, "%v jars checked: %v errors, %v warnings\n", report.Jars, report.Errors, report.Warnings)
+}
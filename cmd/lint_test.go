@@ -0,0 +1,138 @@
+// Copyright © 2018 Steve Huff <steve.huff@asics.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/asicsdigital/masonjar/jar"
+)
+
+func TestLintReportFailed(t *testing.T) {
+	tests := []struct {
+		findings []jar.Finding
+		strict   bool
+		want     bool
+	}{
+		{findings: nil, strict: false, want: false},
+		{findings: nil, strict: true, want: false},
+		{findings: []jar.Finding{{Severity: jar.SeverityWarning}}, strict: false, want: false},
+		{findings: []jar.Finding{{Severity: jar.SeverityWarning}}, strict: true, want: true},
+		{findings: []jar.Finding{{Severity: jar.SeverityError}}, strict: false, want: true},
+		{findings: []jar.Finding{{Severity: jar.SeverityError}, {Severity: jar.SeverityWarning}}, strict: true, want: true},
+	}
+
+	for _, test := range tests {
+		report := lintReport{}
+
+		for _, f := range test.findings {
+			report.add(f)
+		}
+
+		if got := report.failed(test.strict); got != test.want {
+			t.Errorf("%v errors and %v warnings with strict %v: got %v, want %v", report.Errors, report.Warnings, test.strict, got, test.want)
+		}
+	}
+}
+
+func TestSelectLintJars(t *testing.T) {
+	dir, err := ioutil.TempDir("", "masonjar-lint")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	for name, metadata := range map[string]string{
+		"base/metadata.yaml":       "prefix: base-\n",
+		"aws/lambda/metadata.yaml": "prefix: lambda-\n",
+		"broken/metadata.yaml":     "prefix: [\n",
+	} {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ioutil.WriteFile(file, []byte(metadata), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	jars, invalid, err := jar.ParseDirectory(dir)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	invalid = append(invalid, jar.InvalidJar{Name: "platform/old", Repository: "platform", Error: "invalid metadata"})
+
+	tests := []struct {
+		names       []string
+		wantJars    []string
+		wantInvalid []string
+		wantErr     string
+	}{
+		{names: []string{"base"}, wantJars: []string{"base"}},
+		{names: []string{"aws/lambda", "base"}, wantJars: []string{"aws/lambda", "base"}},
+		{names: []string{"broken"}, wantInvalid: []string{"broken"}},
+		{names: []string{"old"}, wantInvalid: []string{"platform/old"}},
+		{names: []string{"platform/old", "base"}, wantJars: []string{"base"}, wantInvalid: []string{"platform/old"}},
+		{names: []string{"base", "missing", "gone"}, wantErr: "Unable to find a jar matching 'missing', 'gone'."},
+	}
+
+	for _, test := range tests {
+		selected, selectedInvalid, err := selectLintJars(test.names, jars, invalid, nil)
+
+		if len(test.wantErr) > 0 {
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("%v: got error %v, want %q", test.names, err, test.wantErr)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%v: %v", test.names, err)
+			continue
+		}
+
+		var gotJars, gotInvalid []string
+
+		for _, j := range selected {
+			gotJars = append(gotJars, j.Name())
+		}
+
+		for _, j := range selectedInvalid {
+			gotInvalid = append(gotInvalid, j.Name)
+		}
+
+		if !reflect.DeepEqual(gotJars, test.wantJars) || !reflect.DeepEqual(gotInvalid, test.wantInvalid) {
+			t.Errorf("%v: got jars %v and invalid jars %v, want %v and %v", test.names, gotJars, gotInvalid, test.wantJars, test.wantInvalid)
+		}
+	}
+}
@@ -22,6 +22,9 @@ package cmd
 
 import (
 	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
 
 	"github.com/asicsdigital/masonjar/jar"
 	"github.com/spf13/cobra"
@@ -29,26 +32,114 @@ import (
 	"github.com/spf13/viper"
 )
 
+var listTags, listRepos []string
+
 // listCmd represents the list command
 var listCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List available jars",
-	Long: `List jars downloaded from the Git repository.
+	Long: `List jars downloaded from the Git repositories.
+
+When more than one repository is configured, jars are listed as
+"repository/jar".  Use "masonjar update" to download the latest versions.
+
+By default each jar's repository, description, tags and the last commit
+which changed it are shown in a table.  Use --output names for just the
+names, or --output json or --output yaml for output which scripts can read.
 
-Use "masonjar update" to download the latest versions.`,
+--tag and --repo, which can be repeated, list only the jars with all of the
+given tags and the jars in any of the given repositories.  --deprecated lists
+only deprecated jars.  Jars which could not be parsed are left out, unless
+--include-invalid is given.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		jww.DEBUG.Println("list called")
 
-		jars, _ := jar.ParseJars(viper.GetString("RepoDir"))
+		format := viper.GetString("ListOutput")
+		err := checkOutputFormat(format, "table", "json", "yaml", "names")
+
+		if err != nil {
+			jww.ERROR.Println(err)
+			os.Exit(1)
+		}
+
+		repos, err := configuredRepositories()
+
+		if err != nil {
+			jww.ERROR.Println(err)
+			os.Exit(1)
+		}
+
+		labels, err := selectedLabels(repos, listRepos)
+
+		if err != nil {
+			jww.ERROR.Println(err)
+			os.Exit(1)
+		}
+
+		jars, invalid, err := jar.ParseCatalog(repos)
+
+		if jars == nil && invalid == nil && err != nil {
+			jww.ERROR.Println(err)
+			os.Exit(1)
+		}
+
+		var entries []listEntry
 
 		for i := range jars {
-			j := jars[i]
+			summary := jar.Summarize(jars[i])
+
+			if labels[summary.Repository] && hasTags(summary, listTags) && (summary.Deprecated || !viper.GetBool("ListDeprecated")) {
+				entries = append(entries, listEntry{Summary: summary, jar: jars[i]})
+			}
+		}
+
+		if format != "names" {
+			addLastModified(entries, repos)
+		}
+
+		var invalidEntries []listEntry
+		filtered := len(listTags) > 0 || viper.GetBool("ListDeprecated")
 
-			fmt.Println(j.Name())
+		for _, j := range invalid {
+			if labels[j.Repository] && !filtered {
+				invalidEntries = append(invalidEntries, listEntry{Summary: jar.Summary{Name: j.Name, Repository: j.Repository}, Error: j.Error})
+			}
+		}
+
+		if len(invalidEntries) > 0 && !viper.GetBool("ListInvalid") {
+			jar.Warn("%v jars could not be parsed; use --include-invalid to list them", len(invalidEntries))
+			invalidEntries = nil
+		}
+
+		switch format {
+		case "table":
+			printJarTable(entries, invalidEntries)
+		case "names":
+			for _, e := range append(entries, invalidEntries...) {
+				fmt.Println(e.Name)
+			}
+		default:
+			all := append([]listEntry{}, entries...)
+			err = writeOutput(format, append(all, invalidEntries...))
+
+			if err != nil {
+				jww.ERROR.Println(err)
+				os.Exit(1)
+			}
 		}
 	},
 }
 
+// listEntry is a jar as shown by "masonjar list".  Jars which could not be
+// parsed have only a name, a repository and an error.
+type listEntry struct {
+	jar.Summary  `yaml:",inline"`
+	LastModified *jar.CommitSummary `json:"last_modified,omitempty" yaml:"last_modified,omitempty"`
+	Error        string             `json:"error,omitempty" yaml:"error,omitempty"`
+
+	jar jar.Jar
+}
+
 func init() {
 	rootCmd.AddCommand(listCmd)
 
@@ -61,4 +152,141 @@ func init() {
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
 	// listCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	listCmd.Flags().StringP("output", "o", "table", "List jars as a table, json, yaml or names")
+	viper.BindPFlag("ListOutput", listCmd.Flags().Lookup("output"))
+
+	listCmd.Flags().StringArrayVar(&listTags, "tag", []string{}, "Only list jars with this tag (can be repeated)")
+
+	listCmd.Flags().StringArrayVar(&listRepos, "repo", []string{}, "Only list jars from the repository with this name or URL (can be repeated)")
+
+	listCmd.Flags().Bool("deprecated", false, "Only list deprecated jars")
+	viper.BindPFlag("ListDeprecated", listCmd.Flags().Lookup("deprecated"))
+
+	listCmd.Flags().Bool("include-invalid", false, "Also list jars which could not be parsed")
+	viper.BindPFlag("ListInvalid", listCmd.Flags().Lookup("include-invalid"))
+}
+
+// selectedLabels returns the labels of the repositories with the given names
+// or URLs, or of all repositories if none are given.  Jars and invalid jars
+// record the label of their repository.
+func selectedLabels(repos []jar.Repository, selected []string) (map[string]bool, error) {
+	labels := map[string]bool{}
+
+	for _, s := range selected {
+		found := false
+
+		for _, r := range repos {
+			if r.Name == s || r.URL == s {
+				labels[r.Label()] = true
+				found = true
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("no repository named %v is configured", s)
+		}
+	}
+
+	if len(selected) == 0 {
+		for _, r := range repos {
+			labels[r.Label()] = true
+		}
+	}
+
+	return labels, nil
+}
+
+func hasTags(summary jar.Summary, tags []string) bool {
+	for _, tag := range tags {
+		found := false
+
+		for _, t := range summary.Tags {
+			if strings.EqualFold(t, tag) {
+				found = true
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// addLastModified records the last commit which changed each jar.
+func addLastModified(entries []listEntry, repos []jar.Repository) {
+	for i := range repos {
+		var names []string
+
+		for _, e := range entries {
+			if e.jar.Repository() != nil && e.jar.Repository().Dir == repos[i].Dir {
+				names = append(names, e.jar.Name())
+			}
+		}
+
+		if len(names) == 0 {
+			continue
+		}
+
+		modified, err := jar.LastModified(&repos[i], names)
+
+		if err != nil {
+			jww.WARN.Printf("unable to find when the jars in %v were changed: %v", repos[i].Dir, err)
+			continue
+		}
+
+		for k := range entries {
+			if entries[k].jar.Repository() != nil && entries[k].jar.Repository().Dir == repos[i].Dir {
+				if commit, ok := modified[entries[k].jar.Name()]; ok {
+					entries[k].LastModified = &commit
+				}
+			}
+		}
+	}
+}
+
+// maxDescription is the widest description shown in the table.
+const maxDescription = 50
+
+func printJarTable(entries []listEntry, invalid []listEntry) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tREPOSITORY\tDESCRIPTION\tTAGS\tMODIFIED")
+
+	for _, e := range entries {
+		name := e.Name
+
+		if e.Deprecated {
+			name += " (deprecated)"
+		}
+
+		description := strings.SplitN(e.Description, "\n", 2)[0]
+
+		if runes := []rune(description); len(runes) > maxDescription {
+			description = string(runes[:maxDescription-3]) + "..."
+		}
+
+		modified := ""
+
+		if e.LastModified != nil {
+			modified = fmt.Sprintf("%v %v", shortHash(e.LastModified.Hash), e.LastModified.Date.Format("2006-01-02"))
+		}
+
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n", name, e.Repository, description, strings.Join(e.Tags, ", "), modified)
+	}
+
+	w.Flush()
+
+	if len(invalid) == 0 {
+		return
+	}
+
+	fmt.Println("\nInvalid jars:")
+	w = tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+
+	for _, e := range invalid {
+		fmt.Fprintf(w, "  %v\t%v\t%v\n", e.Name, e.Repository, strings.Replace(e.Error, "\n", " ", -1))
+	}
+
+	w.Flush()
 }
@@ -21,9 +21,9 @@
 package cmd
 
 import (
-	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/asicsdigital/masonjar/jar"
 	"github.com/spf13/afero"
@@ -32,6 +32,8 @@ import (
 	"github.com/spf13/viper"
 )
 
+var jarNames, setValues, valuesFiles []string
+
 // openCmd represents the open command
 var openCmd = &cobra.Command{
 	Use:   "open",
@@ -39,18 +41,114 @@ var openCmd = &cobra.Command{
 	Long: `Create a new directory by making a copy of an existing jar.
 
 Required parameters are --jar (which must match one of the jar names output by
-"masonjar list") and -identifier (a unique identifier for the copy of the jar).`,
+"masonjar list") and -identifier (a unique identifier for the copy of the jar).
+A jar name without a repository, e.g. "go-service" rather than
+"platform/go-service", is taken from the first configured repository which has
+a jar of that name.  Follow a jar name with @ and a branch, tag or commit SHA,
+e.g. "hello-world@v1.4.0", to open the jar as it was at that revision of its
+repository.
+
+Values for templated files may be supplied with --set, which can be repeated:
+
+$ masonjar open --jar hello-world --identifier jarhead \
+--set owner=platform --set replicas=3 --set datadog.enabled=true
+
+Dotted keys create nested values, "true" and "false" are parsed as booleans,
+numbers as numbers and [a,b,c] as lists; quote a value to keep it a string.
+Values may also be read from YAML, JSON or TOML files with --values, which can
+be repeated; later files take precedence over earlier ones, and --set takes
+precedence over all of them.  Values given either way override defaults from
+the jar's metadata.  If the jar declares variables, every value is validated
+before any files are written.
+
+Template expressions may also be used in the names of files and directories
+in a jar, e.g. "cmd/{{ .Identifier }}/main.go", and jars may declare conditions
+under which some of their files are left out.  A jar which extends another jar
+is laid down on top of its parent.
+
+Several jars may be opened together in one destination by repeating --jar:
+
+$ masonjar open --jar base --jar go-service --jar datadog-monitoring \
+--identifier jarhead
+
+The jars are laid down in order and their variables are merged, so each value
+is asked for once.  Where more than one jar provides the same file, the last
+jar given wins; these conflicts are reported before anything is written.
+Hooks are run for each jar in turn.
+
+When run in a terminal, masonjar prompts for any declared variables which were
+not given with --set or --values.  Otherwise, or with --no-input, it fails if
+a required variable has no value.
+
+Before anything is written, the preconditions declared in the jar's metadata
+are checked and its pre_open hooks are run in the destination directory.  Once
+the jar's files have been written, its post_open hooks are run in the new
+directory.  Use --no-hooks to skip both kinds of hook, and the commands
+preconditions run to check versions.
+
+Hooks run arbitrary commands, so masonjar asks before running a jar's hooks
+or version checks for the first time, and again whenever they change.  Non-interactive sessions
+refuse to run hooks which have not been trusted, unless --trust is given or
+the jar repository is listed in the TrustedRepositories configuration key.
+
+Use --save-values to write the resolved values, including any answers to
+prompts, to a file which can be passed to --values later.  Secret variables
+are never saved.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		jww.DEBUG.Println("open called")
 
-		jars, _ := jar.ParseJars(viper.GetString("RepoDir"))
+		values, err := jar.ReadValuesFiles(valuesFiles)
+
+		if err != nil {
+			jww.ERROR.Println(err)
+			os.Exit(1)
+		}
+
+		overrides, err := jar.ParseSetValues(setValues)
+
+		if err != nil {
+			jww.ERROR.Println(err)
+			os.Exit(1)
+		}
+
+		viper.Set("JarValues", values.Merge(overrides))
 
-		targetJar := viper.GetString("JarSource")
+		repos, err := configuredRepositories()
+
+		if err != nil {
+			jww.ERROR.Println(err)
+			os.Exit(1)
+		}
+
+		jars, _ := jar.ParseRepositoryJars(repos)
+
+		selected, missing, err := jar.FindJars(jarNames, jars, repos)
+
+		if err != nil {
+			jww.ERROR.Println(err)
+			os.Exit(1)
+		}
 
-		if !jar.MatchJar(targetJar, jars, jarWalkFunc) {
-			jww.ERROR.Printf("Unable to find a jar matching '%v'.  Use `masonjar list` to list available jars.", targetJar)
+		if len(missing) > 0 {
+			jww.ERROR.Printf("Unable to find a jar matching '%v'.  Use `masonjar list` to list available jars.", strings.Join(missing, "', '"))
 			os.Exit(1)
 		}
+
+		err = jar.OpenJars(selected, jarWalkFunc, promptFunc(), confirmFunc())
+
+		if err != nil {
+			jww.ERROR.Println(err)
+			os.Exit(1)
+		}
+
+		if saveFile := viper.GetString("SaveValuesFile"); len(saveFile) > 0 {
+			err = saveValues(saveFile)
+
+			if err != nil {
+				jww.ERROR.Println(err)
+				os.Exit(1)
+			}
+		}
 	},
 }
 
@@ -66,9 +164,8 @@ func init() {
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
 	// openCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
-	openCmd.Flags().String("jar", "", "Name of the jar to be used as a source (required)")
+	openCmd.Flags().StringArrayVar(&jarNames, "jar", []string{}, "Name of a jar to be used as a source (required, can be repeated)")
 	openCmd.MarkFlagRequired("jar")
-	viper.BindPFlag("JarSource", openCmd.Flags().Lookup("jar"))
 
 	openCmd.Flags().String("identifier", "", "Identifier for the jar to be created (required)")
 	openCmd.MarkFlagRequired("identifier")
@@ -77,6 +174,37 @@ func init() {
 	openCmd.Flags().String("destination", ".", "Path in local filesystem where jar will be created")
 	viper.BindPFlag("JarDestination", openCmd.Flags().Lookup("destination"))
 
+	openCmd.Flags().StringArrayVar(&setValues, "set", []string{}, "Set a template value as key=value (can be repeated)")
+
+	openCmd.Flags().StringArrayVar(&valuesFiles, "values", []string{}, "Read template values from a YAML, JSON or TOML file (can be repeated)")
+
+	openCmd.Flags().String("save-values", "", "Write the resolved template values to a YAML, JSON or TOML file")
+	viper.BindPFlag("SaveValuesFile", openCmd.Flags().Lookup("save-values"))
+
+	openCmd.Flags().Bool("no-hooks", false, "Do not run the jar's hooks")
+	viper.BindPFlag("NoHooks", openCmd.Flags().Lookup("no-hooks"))
+
+	openCmd.Flags().Bool("trust", false, "Trust the jar's hooks without asking")
+	viper.BindPFlag("TrustHooks", openCmd.Flags().Lookup("trust"))
+}
+
+func saveValues(filename string) error {
+	values := viper.Get("CurrentJarValues").(jar.Values)
+	variables, _ := jar.ParseVariables(viper.Get("CurrentJarMetadata").(*viper.Viper))
+
+	for i := range variables {
+		if variables[i].Secret {
+			jww.WARN.Printf("not saving secret variable %v to %v", variables[i].Name, filename)
+		}
+	}
+
+	err := jar.WriteValuesFile(filename, values.WithoutSecrets(variables))
+
+	if err == nil {
+		jww.INFO.Printf("saved values to %v", filename)
+	}
+
+	return err
 }
 
 func jarWalkFunc(path string, info os.FileInfo, err error) error {
@@ -85,14 +213,24 @@ func jarWalkFunc(path string, info os.FileInfo, err error) error {
 		return filepath.SkipDir
 	}
 
-	if isSkippable(path) {
+	if jar.IsSkippable(path) {
 		jww.INFO.Printf("skipping %v", path)
 		return nil
 	}
 
+	if jar.IsExcluded(path, viper.GetStringSlice("CurrentJarExclusions")) {
+		jww.INFO.Printf("excluding %v", path)
+
+		if info.IsDir() {
+			return filepath.SkipDir
+		}
+
+		return nil
+	}
+
 	// no error, not skippable
 	// time to actually do something with the path
-	srcFs := afero.NewBasePathFs(afero.NewReadOnlyFs(afero.NewOsFs()), viper.GetString("CurrentJarPath"))
+	srcFs := viper.Get("CurrentJarFs").(afero.Fs)
 
 	destRoot := viper.GetString("DestRoot")
 	destFs := afero.NewBasePathFs(afero.NewOsFs(), destRoot)
@@ -105,55 +243,38 @@ func processJarPath(path string, srcFs afero.Fs, destFs afero.Fs) error {
 	sfs := &afero.Afero{Fs: srcFs}
 	isDir, err := sfs.IsDir(path)
 
-	// create directories and set mode
+	destPath, ok := viper.Get("CurrentJarPaths").(map[string]string)[path]
+
+	// the file is replaced by a later jar
+	if !ok {
+		jww.DEBUG.Printf("skipping %v, which is replaced by another jar", path)
+		return nil
+	}
+
+	// create directories and set mode; a directory may already have been
+	// created by a jar this one extends
 	if isDir {
-		fileInfo, _ := srcFs.(*afero.BasePathFs).Stat(path)
+		dfs := &afero.Afero{Fs: destFs}
+
+		if exists, _ := dfs.DirExists(destPath); exists {
+			return nil
+		}
+
+		fileInfo, _ := srcFs.Stat(path)
 		fileMode := fileInfo.Mode()
-		return destFs.(*afero.BasePathFs).Mkdir(path, fileMode)
+		return destFs.(*afero.BasePathFs).Mkdir(destPath, fileMode)
 	}
 
 	metadata := viper.Get("CurrentJarMetadata").(*viper.Viper)
 
 	// process templates
-	if isTemplate(path, metadata) {
-		return jar.ProcessTemplate(path, metadata)
+	if jar.IsTemplate(path, metadata) {
+		data := viper.Get("CurrentJarTemplateData").(jar.TemplateData)
+		return jar.ProcessTemplate(path, destPath, srcFs, destFs, data)
 	}
 
 	// copy non-template files
-	err = jar.CopyFile(path, srcFs, destFs)
+	err = jar.CopyFile(path, destPath, srcFs, destFs)
 
 	return err
 }
-
-func isTemplate(path string, metadata *viper.Viper) bool {
-	filename := filepath.Base(path)
-	template_spec := fmt.Sprintf("%s.%s", "templates", filename)
-
-	if !metadata.IsSet(template_spec) {
-		jww.DEBUG.Printf("no template specification for %v", template_spec)
-		return false
-	}
-
-	jww.INFO.Printf("found template specification for %v", template_spec)
-	return true
-}
-
-func isSkippable(path string) bool {
-	metadataMatch, err := filepath.Match(fmt.Sprintf("/%s.*", jar.MetadataFileName), path)
-
-	if err != nil {
-		jww.WARN.Println(err)
-		return false
-	}
-
-	if metadataMatch {
-		return true
-	}
-
-	switch path {
-	case "/", "/templates":
-		return true
-	default:
-		return false
-	}
-}
@@ -0,0 +1,187 @@
+// Copyright © 2018 Steve Huff <steve.huff@asics.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/asicsdigital/masonjar/jar"
+	jww "github.com/spf13/jwalterweatherman"
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+var stdinReader = bufio.NewReader(os.Stdin)
+
+// isInteractive reports whether masonjar may prompt the user for input.
+func isInteractive() bool {
+	if viper.GetBool("NoInput") {
+		return false
+	}
+
+	return terminal.IsTerminal(int(os.Stdin.Fd()))
+}
+
+// promptFunc returns the function used to ask for missing variables, or nil
+// if the session is not interactive.
+func promptFunc() jar.PromptFunc {
+	if !isInteractive() {
+		jww.DEBUG.Println("not prompting for variables in a non-interactive session")
+		return nil
+	}
+
+	return promptVariable
+}
+
+// confirmFunc returns the function used to ask yes/no questions, or nil if
+// the session is not interactive.
+func confirmFunc() jar.ConfirmFunc {
+	if !isInteractive() {
+		return nil
+	}
+
+	return confirm
+}
+
+// passphraseFunc returns the function used to ask for secrets such as SSH key
+// passphrases, or nil if the session is not interactive.
+func passphraseFunc() jar.PassphraseFunc {
+	if !isInteractive() {
+		return nil
+	}
+
+	return func(prompt string) (string, error) {
+		fmt.Fprintln(os.Stderr)
+		return promptString(prompt, true)
+	}
+}
+
+// confirm asks a yes/no question, defaulting to no.
+func confirm(question string) (bool, error) {
+	fmt.Fprintln(os.Stderr)
+	answer, err := promptString(fmt.Sprintf("%v [y/N]", question), false)
+
+	if err != nil {
+		return false, err
+	}
+
+	switch strings.ToLower(answer) {
+	case "y", "yes":
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// promptVariable asks for a variable's value until a valid one is given.
+func promptVariable(v jar.Variable, defaultValue interface{}) (interface{}, error) {
+	fmt.Fprintln(os.Stderr)
+
+	if len(v.Description) > 0 {
+		fmt.Fprintf(os.Stderr, "%v: %v\n", v.Name, v.Description)
+	}
+
+	if len(v.Choices) > 0 {
+		fmt.Fprintf(os.Stderr, "  choices: %v\n", formatList(v.Choices))
+	}
+
+	label := v.Name
+
+	if defaultValue != nil {
+		if v.Secret {
+			label = fmt.Sprintf("%v [********]", label)
+		} else {
+			label = fmt.Sprintf("%v [%v]", label, formatValue(defaultValue))
+		}
+	}
+
+	for {
+		answer, err := promptString(label, v.Secret)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if len(answer) == 0 {
+			if defaultValue != nil {
+				return v.Validate(defaultValue)
+			}
+
+			if !v.Required {
+				return v.ZeroValue(), nil
+			}
+
+			fmt.Fprintf(os.Stderr, "  %v is required\n", v.Name)
+			continue
+		}
+
+		value, err := v.Validate(answer)
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "  %v\n", err)
+			continue
+		}
+
+		return value, nil
+	}
+}
+
+// promptString prints label and reads a line of input, without echoing it
+// if secret is set.
+func promptString(label string, secret bool) (string, error) {
+	fmt.Fprintf(os.Stderr, "%v: ", label)
+
+	if secret {
+		answer, err := terminal.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		return strings.TrimSpace(string(answer)), err
+	}
+
+	answer, err := stdinReader.ReadString('\n')
+
+	if err == io.EOF && len(answer) > 0 {
+		err = nil
+	}
+
+	return strings.TrimSpace(answer), err
+}
+
+func formatValue(value interface{}) string {
+	if list, ok := value.([]interface{}); ok {
+		return formatList(list)
+	}
+
+	return fmt.Sprint(value)
+}
+
+func formatList(list []interface{}) string {
+	items := make([]string, len(list))
+
+	for i := range list {
+		items[i] = fmt.Sprint(list[i])
+	}
+
+	return strings.Join(items, ", ")
+}
@@ -0,0 +1,135 @@
+// Copyright © 2018 Steve Huff <steve.huff@asics.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/asicsdigital/masonjar/jar"
+	jww "github.com/spf13/jwalterweatherman"
+	"github.com/spf13/viper"
+	"gopkg.in/src-d/go-git.v4"
+)
+
+// configuredRepositories returns the jar repositories listed in the
+// configuration, in order of precedence.  Without a list, the single
+// repository given by RepoUrl is used.  Each repository is cached in a
+// directory named after its URL.
+func configuredRepositories() ([]jar.Repository, error) {
+	repos, err := jar.ParseRepositories(viper.GetViper())
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(repos) == 0 {
+		r := jar.Repository{
+			URL:    viper.GetString("RepoUrl"),
+			Remote: viper.GetString("RepoRemote"),
+			Ref:    viper.GetString("RepoRef"),
+		}
+
+		err = viper.UnmarshalKey("RepoAuth", &r.Auth)
+
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse RepoAuth: %v", err)
+		}
+		r.Dir = filepath.Join(viper.GetString("RepoCacheDir"), r.CacheKey())
+		migrateLegacyRepo(r)
+
+		return []jar.Repository{r}, nil
+	}
+
+	for i := range repos {
+		repos[i].Dir = filepath.Join(viper.GetString("RepoCacheDir"), repos[i].CacheKey())
+	}
+
+	return repos, nil
+}
+
+// migrateLegacyRepo moves a clone made by older versions of masonjar, which
+// always used the same directory, into the cache if it is a clone of r.
+func migrateLegacyRepo(r jar.Repository) {
+	legacyDir := FilenameInHomedir("repo")
+
+	if _, err := os.Stat(legacyDir); err != nil {
+		return
+	}
+
+	if _, err := os.Stat(r.Dir); err == nil {
+		return
+	}
+
+	url, err := remoteURL(legacyDir, r.Remote)
+
+	if err != nil || url != r.URL {
+		jww.DEBUG.Printf("not migrating %v, which is a clone of %v", legacyDir, url)
+		return
+	}
+
+	jww.INFO.Printf("moving %v to %v", legacyDir, r.Dir)
+
+	err = os.MkdirAll(filepath.Dir(r.Dir), 0700)
+
+	if err == nil {
+		err = os.Rename(legacyDir, r.Dir)
+	}
+
+	if err != nil {
+		jww.WARN.Printf("unable to move %v to %v: %v", legacyDir, r.Dir, err)
+	}
+}
+
+// remoteURL returns the URL of a remote of the repository cloned in dir.
+func remoteURL(dir string, remoteName string) (string, error) {
+	r, err := git.PlainOpen(dir)
+
+	if err != nil {
+		return "", err
+	}
+
+	remote, err := r.Remote(remoteName)
+
+	if err != nil {
+		return "", err
+	}
+
+	urls := remote.Config().URLs
+
+	if len(urls) == 0 {
+		return "", fmt.Errorf("remote %v of %v has no URL", remoteName, dir)
+	}
+
+	return urls[0], nil
+}
+
+// parseJars parses the jars in every configured repository.
+func parseJars() ([]jar.Jar, error) {
+	repos, err := configuredRepositories()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return jar.ParseRepositoryJars(repos)
+}
@@ -86,6 +86,9 @@ func init() {
 
 	rootCmd.PersistentFlags().BoolP("verbose", "v", false, "Enable verbose logging")
 	viper.BindPFlag("IsVerbose", rootCmd.PersistentFlags().Lookup("verbose"))
+
+	rootCmd.PersistentFlags().Bool("no-input", false, "Never prompt for input")
+	viper.BindPFlag("NoInput", rootCmd.PersistentFlags().Lookup("no-input"))
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -116,7 +119,7 @@ func initConfig() {
 
 	// If a config file is found, read it in.
 	if err := viper.ReadInConfig(); err == nil {
-		fmt.Println("Using config file:", viper.ConfigFileUsed())
+		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
 	}
 
 	// now that the config is read, derive the homedir
@@ -126,8 +129,14 @@ func initConfig() {
 	viper.SetDefault("LogFile", FilenameInHomedir("masonjar.log"))
 	initLogging(viper.GetString("LogFile"))
 
-	// derive repository path
-	viper.Set("RepoDir", FilenameInHomedir("repo"))
+	// repositories are cached beneath this directory
+	viper.Set("RepoCacheDir", FilenameInHomedir("repos"))
+
+	// indexes of the jars in each repository
+	viper.Set("IndexDir", FilenameInHomedir("index"))
+
+	// fingerprints of trusted hooks
+	viper.Set("TrustFile", FilenameInHomedir("trusted_hooks.json"))
 }
 
 func initLogging(logFile string) {
@@ -0,0 +1,178 @@
+// Copyright © 2018 Steve Huff <steve.huff@asics.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/asicsdigital/masonjar/jar"
+	"github.com/spf13/cobra"
+	jww "github.com/spf13/jwalterweatherman"
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// searchCmd represents the search command
+var searchCmd = &cobra.Command{
+	Use:   "search <query>",
+	Short: "Search for jars",
+	Long: `Search the names, tags, descriptions and READMEs of the available jars.
+
+Jars are listed best match first, and must match every word of the query.
+Words match whole words best, then the beginnings and parts of words, words
+with a typo, and finally, in jar names, letters in the same order, so that
+"lgo" finds "lambda-go".  Matches in names count for the most, then tags,
+descriptions and READMEs.  Deprecated jars are ranked lower.
+
+Matches are highlighted when writing to a terminal, unless the NO_COLOR
+environment variable is set.  Use --output json or --output yaml for output
+which scripts can read, including each jar's score and where it matched.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		jww.DEBUG.Println("search called")
+
+		format := viper.GetString("SearchOutput")
+		err := checkOutputFormat(format, "text", "json", "yaml")
+
+		if err != nil {
+			jww.ERROR.Println(err)
+			os.Exit(1)
+		}
+
+		jars, err := parseJars()
+
+		if jars == nil && err != nil {
+			jww.ERROR.Println(err)
+			os.Exit(1)
+		}
+
+		results := jar.Search(jars, strings.Join(args, " "))
+
+		if limit := viper.GetInt("SearchLimit"); limit > 0 && len(results) > limit {
+			results = results[:limit]
+		}
+
+		if format != "text" {
+			err = writeOutput(format, results)
+
+			if err != nil {
+				jww.ERROR.Println(err)
+				os.Exit(1)
+			}
+
+			return
+		}
+
+		if len(results) == 0 {
+			fmt.Printf("No jars match %q.\n", strings.Join(args, " "))
+			return
+		}
+
+		color := terminal.IsTerminal(int(os.Stdout.Fd())) && len(os.Getenv("NO_COLOR")) == 0
+
+		for i := range results {
+			if i > 0 {
+				fmt.Println()
+			}
+
+			printSearchResult(results[i], color)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(searchCmd)
+
+	searchCmd.Flags().StringP("output", "o", "text", "Show results as text, json or yaml")
+	viper.BindPFlag("SearchOutput", searchCmd.Flags().Lookup("output"))
+
+	searchCmd.Flags().Int("limit", 20, "Show at most this many jars (0 for no limit)")
+	viper.BindPFlag("SearchLimit", searchCmd.Flags().Lookup("limit"))
+}
+
+func printSearchResult(result jar.SearchResult, color bool) {
+	matches := map[string]jar.SearchMatch{}
+
+	for _, m := range result.Matches {
+		matches[m.Field] = m
+	}
+
+	line := highlight(result.Name, matches["name"].Ranges, color)
+
+	if len(result.Tags) > 0 {
+		line += " [" + highlight(strings.Join(result.Tags, ", "), matches["tags"].Ranges, color) + "]"
+	}
+
+	if result.Deprecated {
+		line += " (deprecated)"
+	}
+
+	fmt.Println(line)
+
+	if m, ok := matches["description"]; ok {
+		fmt.Println("    " + highlight(m.Text, m.Ranges, color))
+	} else if len(result.Description) > 0 {
+		fmt.Println("    " + strings.SplitN(result.Description, "\n", 2)[0])
+	}
+
+	if m, ok := matches["readme"]; ok {
+		text, ranges := trimSpace(m.Text, m.Ranges)
+		fmt.Println("    README: " + highlight(text, ranges, color))
+	}
+}
+
+// highlight marks the given ranges of text in bold yellow.
+func highlight(text string, ranges [][2]int, color bool) string {
+	if !color || len(ranges) == 0 {
+		return text
+	}
+
+	var b strings.Builder
+	last := 0
+
+	for _, r := range ranges {
+		b.WriteString(text[last:r[0]])
+		b.WriteString("\x1b[1;33m")
+		b.WriteString(text[r[0]:r[1]])
+		b.WriteString("\x1b[0m")
+		last = r[1]
+	}
+
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// trimSpace removes leading and trailing space from text, adjusting ranges
+// of the text to match.
+func trimSpace(text string, ranges [][2]int) (string, [][2]int) {
+	trimmed := strings.TrimLeftFunc(text, unicode.IsSpace)
+	shift := len(text) - len(trimmed)
+	var shifted [][2]int
+
+	for _, r := range ranges {
+		shifted = append(shifted, [2]int{r[0] - shift, r[1] - shift})
+	}
+
+	return strings.TrimRightFunc(trimmed, unicode.IsSpace), shifted
+}
@@ -0,0 +1,261 @@
+// Copyright © 2018 Steve Huff <steve.huff@asics.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/asicsdigital/masonjar/jar"
+	"github.com/spf13/cobra"
+	jww "github.com/spf13/jwalterweatherman"
+	"github.com/spf13/viper"
+)
+
+// showCmd represents the show command
+var showCmd = &cobra.Command{
+	Use:   "show <jar>",
+	Short: "Show what a jar does",
+	Long: `Show a jar's description, prefix, maintainers and tags, the variables it
+declares, its hooks, the files it lays down and its README.
+
+The jar may be named in any of the ways accepted by "masonjar open --jar",
+including "repository/jar" and "jar@ref".  Files marked "template" are
+rendered as templates, and files which the jar inherits are marked with the
+jar they come from.  Use --output json or --output yaml for output which
+scripts can read.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		jww.DEBUG.Println("show called")
+
+		format := viper.GetString("ShowOutput")
+		err := checkOutputFormat(format, "text", "json", "yaml")
+
+		if err != nil {
+			jww.ERROR.Println(err)
+			os.Exit(1)
+		}
+
+		repos, err := configuredRepositories()
+
+		if err != nil {
+			jww.ERROR.Println(err)
+			os.Exit(1)
+		}
+
+		jars, _ := jar.ParseRepositoryJars(repos)
+
+		found, missing, err := jar.FindJars(args, jars, repos)
+
+		if err != nil {
+			jww.ERROR.Println(err)
+			os.Exit(1)
+		}
+
+		if len(missing) > 0 {
+			jww.ERROR.Printf("Unable to find a jar matching '%v'.  Use `masonjar list` to list available jars.", missing[0])
+			os.Exit(1)
+		}
+
+		description, err := jar.Describe(found[0])
+
+		if err != nil {
+			jww.ERROR.Printf("jar %v: %v", jar.QualifiedName(found[0]), err)
+			os.Exit(1)
+		}
+
+		if format != "text" {
+			err = writeOutput(format, description)
+
+			if err != nil {
+				jww.ERROR.Println(err)
+				os.Exit(1)
+			}
+
+			return
+		}
+
+		printDescription(description)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(showCmd)
+
+	showCmd.Flags().StringP("output", "o", "text", "Show the jar as text, json or yaml")
+	viper.BindPFlag("ShowOutput", showCmd.Flags().Lookup("output"))
+}
+
+func printDescription(d *jar.Description) {
+	fmt.Println(d.Name)
+
+	if d.Deprecated {
+		notice := "DEPRECATED"
+
+		if len(d.DeprecationNote) > 0 {
+			notice += ": " + d.DeprecationNote
+		}
+
+		fmt.Println()
+		fmt.Println(indent(notice, "  "))
+	}
+
+	if len(d.Description) > 0 {
+		fmt.Println()
+		fmt.Println(indent(d.Description, "  "))
+	}
+
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+
+	if len(d.Repository) > 0 {
+		fmt.Fprintf(w, "  repository:\t%v\n", d.Repository)
+	}
+
+	if len(d.Extends) > 0 {
+		fmt.Fprintf(w, "  extends:\t%v\n", d.Extends)
+	}
+
+	fmt.Fprintf(w, "  prefix:\t%v\n", d.Prefix)
+
+	if len(d.Maintainers) > 0 {
+		fmt.Fprintf(w, "  maintainers:\t%v\n", strings.Join(d.Maintainers, ", "))
+	}
+
+	if len(d.Tags) > 0 {
+		fmt.Fprintf(w, "  tags:\t%v\n", strings.Join(d.Tags, ", "))
+	}
+
+	w.Flush()
+
+	if len(d.Variables) > 0 {
+		fmt.Println("\nVariables:")
+		w = tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+
+		for _, v := range d.Variables {
+			fmt.Fprintf(w, "  %v\t%v\t%v\t%v\n", v.Name, variableType(v), variableDefault(v), v.Description)
+		}
+
+		w.Flush()
+	}
+
+	if len(d.Hooks) > 0 {
+		fmt.Println("\nHooks:")
+		w = tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+
+		for _, stage := range []string{jar.HookStagePreOpen, jar.HookStagePostOpen} {
+			for _, hook := range d.Hooks[stage] {
+				command := strings.Join(hook.Command, " ")
+
+				if len(hook.Dir) > 0 {
+					command = fmt.Sprintf("%v (in %v)", command, hook.Dir)
+				}
+
+				fmt.Fprintf(w, "  %v:\t%v\n", stage, command)
+			}
+		}
+
+		w.Flush()
+	}
+
+	fmt.Println("\nFiles:")
+	printFileTree(d.Files)
+
+	if len(d.Readme) > 0 {
+		fmt.Println("\nREADME:")
+		fmt.Println(indent(strings.TrimRight(d.Readme, "\n"), "  "))
+	}
+}
+
+// printFileTree prints files, which must be sorted by path, as an indented
+// tree, noting which are templates and which are inherited.
+func printFileTree(files []jar.FileDescription) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	var previous []string
+
+	for _, f := range files {
+		parts := strings.Split(f.Path, "/")
+		dirs := parts[:len(parts)-1]
+		common := 0
+
+		for common < len(dirs) && common < len(previous) && dirs[common] == previous[common] {
+			common++
+		}
+
+		for i := common; i < len(dirs); i++ {
+			fmt.Fprintf(w, "  %v%v/\t\n", strings.Repeat("  ", i), dirs[i])
+		}
+
+		var notes []string
+
+		if f.Template {
+			notes = append(notes, "template")
+		}
+
+		if len(f.From) > 0 {
+			notes = append(notes, "from "+f.From)
+		}
+
+		fmt.Fprintf(w, "  %v%v\t%v\n", strings.Repeat("  ", len(dirs)), parts[len(parts)-1], strings.Join(notes, ", "))
+		previous = dirs
+	}
+
+	w.Flush()
+}
+
+func variableType(v jar.Variable) string {
+	t := v.Type
+
+	if v.Type == jar.VariableTypeChoice {
+		var choices []string
+
+		for _, c := range v.Choices {
+			choices = append(choices, fmt.Sprint(c))
+		}
+
+		t = fmt.Sprintf("choice of %v", strings.Join(choices, ", "))
+	}
+
+	if v.Secret {
+		t += " (secret)"
+	}
+
+	return t
+}
+
+func variableDefault(v jar.Variable) string {
+	switch {
+	case v.Secret && v.Default != nil:
+		return "default (hidden)"
+	case v.Default != nil:
+		return fmt.Sprintf("default %v", v.Default)
+	case v.Required:
+		return "required"
+	default:
+		return "optional"
+	}
+}
+
+func indent(text string, prefix string) string {
+	return prefix + strings.Replace(text, "\n", "\n"+prefix, -1)
+}
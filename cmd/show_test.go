@@ -0,0 +1,134 @@
+// Copyright © 2018 Steve Huff <steve.huff@asics.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/asicsdigital/masonjar/jar"
+)
+
+// captureStdout returns what f prints to the standard output.
+func captureStdout(t *testing.T, f func()) string {
+	r, w, err := os.Pipe()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stdout := os.Stdout
+	os.Stdout = w
+
+	defer func() {
+		os.Stdout = stdout
+	}()
+
+	f()
+	w.Close()
+
+	out, err := ioutil.ReadAll(r)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(out)
+}
+
+func TestPrintDescription(t *testing.T) {
+	d := &jar.Description{
+		Summary: jar.Summary{
+			Name:            "platform/child",
+			Repository:      "platform",
+			Description:     "A child jar.",
+			Tags:            []string{"go", "lambda"},
+			Deprecated:      true,
+			DeprecationNote: "use lambda-go",
+		},
+		Extends: "platform/base",
+		Prefix:  "child-",
+		Variables: []jar.Variable{
+			{Name: "region", Type: jar.VariableTypeString, Default: "us-east-1", Description: "AWS region"},
+			{Name: "token", Type: jar.VariableTypeString, Default: "********", Secret: true},
+			{Name: "tier", Type: jar.VariableTypeChoice, Choices: []interface{}{"web", "worker"}, Required: true},
+		},
+		Hooks: map[string][]jar.Hook{
+			jar.HookStagePostOpen: {{Command: []string{"make", "init"}, Dir: "build"}},
+		},
+		Files: []jar.FileDescription{
+			{Path: "Makefile", From: "platform/base"},
+			{Path: "cmd/main.go", Template: true},
+		},
+		Readme: "# child\n",
+	}
+
+	got := captureStdout(t, func() {
+		printDescription(d)
+	})
+
+	// tabwriter pads the last column of each line
+	lines := strings.Split(got, "\n")
+
+	for i := range lines {
+		lines[i] = strings.TrimRight(lines[i], " ")
+	}
+
+	got = strings.Join(lines, "\n")
+
+	want := `platform/child
+
+  DEPRECATED: use lambda-go
+
+  A child jar.
+
+  repository:  platform
+  extends:     platform/base
+  prefix:      child-
+  tags:        go, lambda
+
+Variables:
+  region  string                 default us-east-1  AWS region
+  token   string (secret)        default (hidden)
+  tier    choice of web, worker  required
+
+Hooks:
+  post_open:  make init (in build)
+
+Files:
+  Makefile   from platform/base
+  cmd/
+    main.go  template
+
+README:
+  # child
+`
+
+	if got != want {
+		t.Errorf("got:\n%v\nwant:\n%v", got, want)
+	}
+
+	if strings.Contains(got, "********") {
+		t.Error("expected the secret default to be hidden")
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright © 2018 Steve Huff <steve.huff@asics.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/asicsdigital/masonjar/jar"
+	"github.com/spf13/cobra"
+	jww "github.com/spf13/jwalterweatherman"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+// statusCmd represents the status command
+var statusCmd = &cobra.Command{
+	Use:   "status [repository...]",
+	Short: "Show which version of each jar repository is in use",
+	Long: `Show the branch, tag or commit from which jars are read in each jar
+repository, along with the ref it is configured to follow.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		jww.DEBUG.Println("status called")
+
+		repos, err := configuredRepositories()
+
+		if err != nil {
+			jww.ERROR.Println(err)
+			os.Exit(1)
+		}
+
+		for i, r := range selectRepositories(repos, args) {
+			if i > 0 {
+				fmt.Println()
+			}
+
+			printStatus(r)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+}
+
+func printStatus(r jar.Repository) {
+	if len(r.Name) > 0 {
+		fmt.Println(r.Name)
+	}
+
+	ref := r.Ref
+
+	if len(ref) == 0 {
+		ref = "default branch"
+	}
+
+	fmt.Printf("  url:         %v\n", r.URL)
+	fmt.Printf("  directory:   %v\n", r.Dir)
+	fmt.Printf("  ref:         %v\n", ref)
+
+	repo, err := git.PlainOpen(r.Dir)
+
+	if err == git.ErrRepositoryNotExists {
+		fmt.Println("  not downloaded; run \"masonjar update\"")
+		return
+	}
+
+	if err != nil {
+		fmt.Printf("  error:       %v\n", err)
+		return
+	}
+
+	head, err := repo.Head()
+
+	if err != nil {
+		fmt.Printf("  error:       %v\n", err)
+		return
+	}
+
+	fmt.Printf("  using:       %v\n", describeHead(repo, head))
+
+	commit, err := repo.CommitObject(head.Hash())
+
+	if err != nil {
+		fmt.Printf("  error:       %v\n", err)
+		return
+	}
+
+	subject := strings.SplitN(strings.TrimSpace(commit.Message), "\n", 2)[0]
+	fmt.Printf("  commit:      %v %v %v\n", commit.Hash, commit.Committer.When.Format("2006-01-02"), subject)
+}
+
+// describeHead names what HEAD points at: a branch, the tags pointing at a
+// detached HEAD, or just the commit.
+func describeHead(repo *git.Repository, head *plumbing.Reference) string {
+	if head.Name().IsBranch() {
+		return fmt.Sprintf("branch %v", head.Name().Short())
+	}
+
+	var tags []string
+	iter, err := repo.Tags()
+
+	if err == nil {
+		iter.ForEach(func(ref *plumbing.Reference) error {
+			if hash, err := jar.PeelTag(repo, ref.Hash()); err == nil && hash == head.Hash() {
+				tags = append(tags, ref.Name().Short())
+			}
+
+			return nil
+		})
+	}
+
+	if len(tags) > 0 {
+		return fmt.Sprintf("tag %v (detached)", strings.Join(tags, ", "))
+	}
+
+	return fmt.Sprintf("commit %v (detached)", head.Hash())
+}
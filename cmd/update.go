@@ -21,44 +21,269 @@
 package cmd
 
 import (
+	"errors"
+	"fmt"
 	"os"
+	"sort"
+	"strings"
 
+	"github.com/asicsdigital/masonjar/jar"
 	"github.com/spf13/cobra"
 	jww "github.com/spf13/jwalterweatherman"
 	"github.com/spf13/viper"
 	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
 )
 
 // updateCmd represents the update command
 var updateCmd = &cobra.Command{
-	Use:   "update",
+	Use:   "update [repository...]",
 	Short: "Get the latest masonjar definitions",
-	Long:  `Update jar definitions from GitHub.`,
+	Long: `Update jar definitions from GitHub.
+
+Every repository listed under "repositories" in the configuration file is
+updated, unless the names of some repositories are given.
+
+Each repository follows its default branch, unless a branch, tag or commit
+SHA is given with "ref" in its configuration, with the RepoRef configuration
+key, or with --ref, so that jars can be pinned to an approved release.
+
+Repositories are kept as bare clones, and jars are read straight from the
+commit selected by the last update.  Each update is made to a copy of the
+clone, which only replaces the clone once it succeeds.
+
+A branch which has been rewritten on the remote, so that it can't be
+fast-forwarded, is reported rather than updated; use --reset to follow the
+remote anyway.  Clones made by older versions of masonjar are replaced with
+bare clones unless they have local changes or commits, which --reset
+discards.  Use --reclone to replace a damaged clone with a new one.
+
+Once a repository has been updated, the commits fetched and the jars which
+were added, removed or modified are listed, along with any jars whose
+metadata can no longer be parsed.  Use --output json or --output yaml for a
+summary which scripts can read.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		jww.DEBUG.Println("update called")
 
-		repoDir := viper.GetString("RepoDir")
-		err := pullRepo(repoDir, viper.GetString("RepoRemote"))
-
-		switch err {
-		case nil:
-		case git.NoErrAlreadyUpToDate:
-			jww.INFO.Println(err)
-			err = nil
-		case git.ErrRepositoryNotExists:
-			jww.INFO.Println(err)
-			err = cloneRepo(repoDir, viper.GetString("RepoUrl"))
-		default:
+		repos, err := configuredRepositories()
+
+		if err != nil {
 			jww.ERROR.Println(err)
+			os.Exit(1)
 		}
 
+		selected := selectRepositories(repos, args)
+
+		if cmd.Flags().Changed("ref") {
+			if len(selected) > 1 {
+				jww.ERROR.Println("--ref can only be used when updating a single repository")
+				os.Exit(1)
+			}
+
+			selected[0].Ref = viper.GetString("RepoRef")
+		}
+
+		format := viper.GetString("UpdateOutput")
+		err = checkOutputFormat(format, "text", "json", "yaml")
+
 		if err != nil {
 			jww.ERROR.Println(err)
 			os.Exit(1)
 		}
+
+		failed := false
+		summaries := []*jar.CatalogChanges{}
+
+		for _, r := range selected {
+			changes, err := updateRepo(r)
+
+			if err != nil {
+				jww.ERROR.Println(err)
+				failed = true
+				continue
+			}
+
+			if changes == nil {
+				continue
+			}
+
+			summaries = append(summaries, changes)
+
+			if format == "text" {
+				printChanges(changes)
+			}
+		}
+
+		if format != "text" {
+			err = writeOutput(format, summaries)
+
+			if err != nil {
+				jww.ERROR.Println(err)
+				failed = true
+			}
+		}
+
+		if failed {
+			os.Exit(1)
+		}
 	},
 }
 
+// selectRepositories returns the repositories with the given names, or all of
+// them if no names are given.
+func selectRepositories(repos []jar.Repository, names []string) []jar.Repository {
+	if len(names) == 0 {
+		return repos
+	}
+
+	var selected []jar.Repository
+
+	for _, name := range names {
+		found := false
+
+		for i := range repos {
+			if repos[i].Name == name {
+				selected = append(selected, repos[i])
+				found = true
+			}
+		}
+
+		if !found {
+			jww.ERROR.Printf("no repository named %v is configured", name)
+			os.Exit(1)
+		}
+	}
+
+	return selected
+}
+
+// updateRepo updates the cached clone of a repository, and returns how the
+// repository's jars changed.
+func updateRepo(r jar.Repository) (*jar.CatalogChanges, error) {
+	if len(r.Name) > 0 {
+		jww.INFO.Printf("updating repository %v from %v", r.Name, r.URL)
+	}
+
+	auth, err := r.AuthMethod(passphraseFunc())
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to authenticate to %v: %v", r.URL, err)
+	}
+
+	before := headHash(r.Dir)
+	err = checkCache(r)
+
+	if err == nil {
+		err = fetchRepo(r.Dir, r.Remote, r.Ref, auth)
+	}
+
+	switch err {
+	case nil:
+	case git.NoErrAlreadyUpToDate:
+		jww.INFO.Println(err)
+		err = nil
+	case git.ErrRepositoryNotExists, errReclone:
+		jww.INFO.Printf("cloning %v into %v", r.URL, r.Dir)
+		err = cloneRepo(r.Dir, r.URL, r.Remote, r.Ref, auth)
+	}
+
+	if err != nil {
+		if len(r.Name) > 0 {
+			return nil, fmt.Errorf("unable to update repository %v: %v", r.Name, err)
+		}
+
+		return nil, err
+	}
+
+	// index the jars now, rather than in the next command to read them
+	if err := jar.IndexRepository(&r); err != nil {
+		jww.WARN.Printf("unable to index the jars in %v: %v", r.Dir, err)
+	}
+
+	changes, err := jar.DiffCatalog(&r, before, headHash(r.Dir))
+
+	if err != nil {
+		jww.WARN.Printf("unable to compare the jars in %v before and after updating: %v", r.Dir, err)
+		return nil, nil
+	}
+
+	return changes, nil
+}
+
+// headHash returns the commit HEAD of a cached clone points at, or the zero
+// hash if there is no usable clone.
+func headHash(dir string) plumbing.Hash {
+	r, err := git.PlainOpen(dir)
+
+	if err != nil {
+		return plumbing.ZeroHash
+	}
+
+	head, err := r.Head()
+
+	if err != nil {
+		return plumbing.ZeroHash
+	}
+
+	return head.Hash()
+}
+
+// maxCommits is the most commits listed by printChanges.
+const maxCommits = 20
+
+// printChanges summarizes the commits and jar changes fetched by an update.
+// Nothing is printed if the repository was already up to date.
+func printChanges(c *jar.CatalogChanges) {
+	if c.From == c.To {
+		return
+	}
+
+	if len(c.From) == 0 {
+		fmt.Printf("%v: cloned at %v\n", c.Repository, shortHash(c.To))
+	} else {
+		fmt.Printf("%v: updated from %v to %v\n", c.Repository, shortHash(c.From), shortHash(c.To))
+	}
+
+	for i, commit := range c.Commits {
+		if i == maxCommits {
+			fmt.Printf("  ... and %v more commits\n", len(c.Commits)-maxCommits)
+			break
+		}
+
+		fmt.Printf("  %v %v\n", shortHash(commit.Hash), commit.Subject)
+	}
+
+	if len(c.Commits) > 0 {
+		fmt.Println()
+	}
+
+	if len(c.Jars) == 0 {
+		fmt.Println("  no jars changed")
+	}
+
+	for _, change := range c.Jars {
+		fmt.Printf("  %-9v %v\n", change.Change+":", change.Name)
+
+		for _, file := range change.Files {
+			fmt.Printf("              %v\n", file)
+		}
+
+		if len(change.Error) > 0 {
+			fmt.Printf("              %v\n", change.Error)
+		}
+	}
+}
+
+func shortHash(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+
+	return hash
+}
+
 func init() {
 	rootCmd.AddCommand(updateCmd)
 
@@ -78,34 +303,428 @@ func init() {
 	updateCmd.Flags().String("remote", "", "Remote of Git repo containing masonjar definitions (default is 'origin')")
 	viper.SetDefault("RepoRemote", "origin")
 	viper.BindPFlag("RepoRemote", updateCmd.Flags().Lookup("remote"))
+
+	updateCmd.Flags().String("ref", "", "Branch, tag or commit SHA to use (default is the default branch)")
+	viper.BindPFlag("RepoRef", updateCmd.Flags().Lookup("ref"))
+
+	updateCmd.Flags().Bool("reset", false, "Follow the remote even if its history was rewritten, discarding local changes")
+	viper.BindPFlag("RepoReset", updateCmd.Flags().Lookup("reset"))
+
+	updateCmd.Flags().Bool("reclone", false, "Replace the cached clone with a new clone")
+	viper.BindPFlag("RepoReclone", updateCmd.Flags().Lookup("reclone"))
+
+	updateCmd.Flags().StringP("output", "o", "text", "Summarize the changes as text, json or yaml")
+	viper.BindPFlag("UpdateOutput", updateCmd.Flags().Lookup("output"))
+}
+
+// errReclone is returned when the cached clone of a repository should be
+// replaced with a new clone.
+var errReclone = errors.New("the repository will be cloned again")
+
+// brokenCacheError is returned when the cached clone of a repository can't
+// be read.
+type brokenCacheError struct {
+	dir string
+	err error
+}
+
+func (e *brokenCacheError) Error() string {
+	return fmt.Sprintf("%v is damaged (%v); run \"masonjar update --reclone\" to clone the repository again", e.dir, e.err)
+}
+
+// divergedError is returned when a branch can't be fast-forwarded to the
+// commit on the remote, usually because the branch was rewritten upstream.
+type divergedError struct {
+	branch   string
+	remote   string
+	local    plumbing.Hash
+	upstream plumbing.Hash
+}
+
+func (e *divergedError) Error() string {
+	return fmt.Sprintf("branch %v has diverged from %v/%v: %v is not an ancestor of %v, so the branch has probably been rewritten; run \"masonjar update --reset\" to use the remote's history", e.branch, e.remote, e.branch, e.local, e.upstream)
+}
+
+// checkCache makes sure that the cached clone of a repository can be updated
+// in place.  It returns git.ErrRepositoryNotExists if there is no clone, and
+// errReclone if the clone should be replaced.
+func checkCache(r jar.Repository) error {
+	if viper.GetBool("RepoReclone") {
+		return errReclone
+	}
+
+	if _, err := os.Stat(r.Dir); os.IsNotExist(err) {
+		return git.ErrRepositoryNotExists
+	}
+
+	repo, err := git.PlainOpen(r.Dir)
+
+	if err != nil {
+		return &brokenCacheError{r.Dir, err}
+	}
+
+	err = verifyRemote(r)
+
+	if err != nil {
+		return err
+	}
+
+	err = checkWorktree(r, repo)
+
+	if err != nil {
+		return err
+	}
+
+	return checkHead(r, repo)
+}
+
+// verifyRemote makes sure that the cached clone of a repository was cloned
+// from the repository's URL, offering to clone it again if not.
+func verifyRemote(r jar.Repository) error {
+	url, err := remoteURL(r.Dir, r.Remote)
+
+	if err != nil {
+		return &brokenCacheError{r.Dir, err}
+	}
+
+	if url == r.URL {
+		return nil
+	}
+
+	question := fmt.Sprintf("%v is a clone of %v rather than %v.  Replace it with a clone of %v?", r.Dir, url, r.URL, r.URL)
+	confirm := confirmFunc()
+
+	if confirm == nil {
+		return fmt.Errorf("%v is a clone of %v rather than %v; run \"masonjar update --reclone\" to replace it", r.Dir, url, r.URL)
+	}
+
+	reclone, err := confirm(question)
+
+	if err != nil {
+		return err
+	}
+
+	if !reclone {
+		return fmt.Errorf("not updating %v", r.Dir)
+	}
+
+	return errReclone
 }
 
-func cloneRepo(destDir string, repoUrl string) error {
+// checkWorktree replaces clones made by older versions of masonjar, which had
+// a worktree, with bare clones.  Jars are read from the object database, so
+// the worktree is never used, but it is only thrown away along with any local
+// changes or commits if --reset is given.
+func checkWorktree(r jar.Repository, repo *git.Repository) error {
+	w, err := repo.Worktree()
+
+	if err == git.ErrIsBareRepository {
+		return nil
+	}
+
+	if err != nil {
+		return &brokenCacheError{r.Dir, err}
+	}
+
+	if viper.GetBool("RepoReset") {
+		jww.INFO.Printf("replacing %v with a bare clone", r.Dir)
+		return errReclone
+	}
+
+	status, err := w.Status()
+
+	if err != nil {
+		return &brokenCacheError{r.Dir, err}
+	}
+
+	if !status.IsClean() {
+		var changed []string
+
+		for path := range status {
+			changed = append(changed, path)
+		}
+
+		sort.Strings(changed)
+		return fmt.Errorf("%v has local changes to %v; run \"masonjar update --reset\" to discard them", r.Dir, strings.Join(changed, ", "))
+	}
+
+	head, err := repo.Head()
+
+	if err == nil && head.Name().IsBranch() {
+		upstream, err := repo.Reference(plumbing.NewRemoteReferenceName(r.Remote, head.Name().Short()), true)
+
+		if err == nil {
+			pushed, err := jar.IsAncestor(repo, head.Hash(), upstream.Hash())
+
+			if err != nil {
+				return &brokenCacheError{r.Dir, err}
+			}
+
+			if !pushed {
+				return fmt.Errorf("branch %v in %v has commits which are not on %v; run \"masonjar update --reset\" to discard them", head.Name().Short(), r.Dir, r.Remote)
+			}
+		}
+	}
+
+	jww.INFO.Printf("replacing %v with a bare clone", r.Dir)
+	return errReclone
+}
+
+// checkHead makes sure that the commit which jars are read from, and
+// everything in it, can be read.
+func checkHead(r jar.Repository, repo *git.Repository) error {
+	head, err := repo.Head()
+
+	if err != nil {
+		return &brokenCacheError{r.Dir, err}
+	}
+
+	commit, err := repo.CommitObject(head.Hash())
+
+	if err != nil {
+		return &brokenCacheError{r.Dir, err}
+	}
+
+	tree, err := commit.Tree()
+
+	if err == nil {
+		err = tree.Files().ForEach(func(*object.File) error {
+			return nil
+		})
+	}
+
+	if err != nil {
+		return &brokenCacheError{r.Dir, err}
+	}
+
+	return nil
+}
+
+// cloneRepo clones a repository into a new directory, which then replaces
+// destDir, so that a failed clone leaves any existing clone untouched.
+func cloneRepo(destDir string, repoUrl string, repoRemote string, ref string, auth transport.AuthMethod) error {
 	jww.DEBUG.Println("cloneRepo called")
-	_, err := git.PlainClone(destDir, false, &git.CloneOptions{
-		URL:      repoUrl,
-		Progress: os.Stderr,
+	staged, err := newCacheDir(destDir)
+
+	if err != nil {
+		return err
+	}
+
+	defer os.RemoveAll(staged)
+
+	r, err := git.PlainClone(staged, true, &git.CloneOptions{
+		URL:        repoUrl,
+		RemoteName: repoRemote,
+		Auth:       auth,
+		Progress:   os.Stderr,
 	})
 
+	if err != nil {
+		return fmt.Errorf("unable to clone %v: %v", repoUrl, err)
+	}
+
+	err = jar.RecordDefaultBranch(r, repoRemote)
+
+	if err != nil {
+		jww.WARN.Printf("unable to record the default branch of %v: %v", repoUrl, err)
+	}
+
+	if len(ref) > 0 {
+		_, err = setHead(r, repoRemote, ref, true)
+
+		if err != nil {
+			return err
+		}
+	}
+
 	jww.DEBUG.Println("cloneRepo returned")
-	return err
+	return swapCache(staged, destDir)
 }
 
-func pullRepo(destDir string, repoRemote string) error {
-	jww.DEBUG.Println("pullRepo called")
-	r, err := git.PlainOpen(destDir)
+// fetchRepo fetches into a copy of the clone in destDir, which replaces it
+// once HEAD has been moved, so that a failed update leaves the clone
+// untouched.  Nothing is copied if the remote hasn't moved and HEAD already
+// points at ref.
+func fetchRepo(destDir string, repoRemote string, ref string, auth transport.AuthMethod) error {
+	jww.DEBUG.Println("fetchRepo called")
+
+	if upToDate(destDir, repoRemote, ref, auth) {
+		jww.DEBUG.Println("fetchRepo returned")
+		return git.NoErrAlreadyUpToDate
+	}
+
+	staged, err := stageCache(destDir)
 
 	if err != nil {
 		return err
 	}
 
-	w, err := r.Worktree()
+	defer os.RemoveAll(staged)
+
+	r, err := git.PlainOpen(staged)
 
-	err = w.Pull(&git.PullOptions{
+	if err != nil {
+		return err
+	}
+
+	before, err := r.Head()
+
+	if err != nil {
+		return err
+	}
+
+	err = r.Fetch(&git.FetchOptions{
 		RemoteName: repoRemote,
+		Auth:       auth,
 		Progress:   os.Stderr,
+		Tags:       git.AllTags,
 	})
 
-	jww.DEBUG.Println("pullRepo returned")
+	if err == plumbing.ErrObjectNotFound {
+		return &brokenCacheError{destDir, err}
+	}
+
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return fmt.Errorf("unable to fetch from %v: %v", repoRemote, err)
+	}
+
+	after, err := setHead(r, repoRemote, ref, viper.GetBool("RepoReset"))
+
+	if err != nil {
+		return err
+	}
+
+	err = swapCache(staged, destDir)
+
+	jww.DEBUG.Println("fetchRepo returned")
+
+	if err == nil && after == before.Hash() {
+		return git.NoErrAlreadyUpToDate
+	}
+
 	return err
 }
+
+// upToDate reports whether the clone in destDir already has every branch and
+// tag on the remote, and HEAD already points at ref.  Any error means that
+// the clone has to be fetched to find out.
+func upToDate(destDir string, repoRemote string, ref string, auth transport.AuthMethod) bool {
+	r, err := git.PlainOpen(destDir)
+
+	if err != nil {
+		return false
+	}
+
+	remote, err := r.Remote(repoRemote)
+
+	if err != nil {
+		return false
+	}
+
+	refs, err := remote.List(&git.ListOptions{Auth: auth})
+
+	if err != nil {
+		jww.DEBUG.Printf("unable to list the references on %v: %v", repoRemote, err)
+		return false
+	}
+
+	for _, remoteRef := range refs {
+		var name plumbing.ReferenceName
+
+		switch {
+		case remoteRef.Name().IsBranch():
+			name = plumbing.NewRemoteReferenceName(repoRemote, remoteRef.Name().Short())
+		case remoteRef.Name().IsTag():
+			name = remoteRef.Name()
+		default:
+			continue
+		}
+
+		local, err := r.Reference(name, false)
+
+		if err != nil || local.Hash() != remoteRef.Hash() {
+			jww.DEBUG.Printf("%v has moved on %v", remoteRef.Name(), repoRemote)
+			return false
+		}
+	}
+
+	if len(ref) == 0 {
+		ref, err = jar.DefaultBranch(r, repoRemote)
+
+		if err != nil {
+			return false
+		}
+	}
+
+	hash, branch, err := jar.ResolveRef(r, repoRemote, ref)
+
+	if err != nil {
+		return false
+	}
+
+	head, err := r.Reference(plumbing.HEAD, false)
+
+	if err != nil {
+		return false
+	}
+
+	if len(branch) == 0 {
+		return head.Type() == plumbing.HashReference && head.Hash() == hash
+	}
+
+	current, err := r.Reference(branch, false)
+
+	return err == nil && head.Target() == branch && current.Hash() == hash
+}
+
+// setHead points HEAD at a branch, tag or commit; jars are read from the
+// commit HEAD points at.  Branches follow the remote, while tags and commits
+// leave HEAD detached.  Without a ref, the default branch is used.  A branch
+// is only moved to a commit which doesn't descend from it if force is set.
+func setHead(r *git.Repository, repoRemote string, ref string, force bool) (plumbing.Hash, error) {
+	var err error
+
+	if len(ref) == 0 {
+		ref, err = jar.DefaultBranch(r, repoRemote)
+
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+	}
+
+	hash, branch, err := jar.ResolveRef(r, repoRemote, ref)
+
+	if err != nil {
+		return hash, err
+	}
+
+	if len(branch) == 0 {
+		jww.INFO.Printf("using %v (%v)", ref, hash)
+		return hash, r.Storer.SetReference(plumbing.NewHashReference(plumbing.HEAD, hash))
+	}
+
+	if current, err := r.Reference(branch, false); err == nil && current.Hash() != hash {
+		forward, err := jar.IsAncestor(r, current.Hash(), hash)
+
+		if err != nil {
+			return hash, fmt.Errorf("unable to compare branch %v with %v/%v: %v", ref, repoRemote, ref, err)
+		}
+
+		if !forward {
+			if !force {
+				return hash, &divergedError{ref, repoRemote, current.Hash(), hash}
+			}
+
+			jww.INFO.Printf("resetting branch %v from %v", ref, current.Hash())
+		}
+	}
+
+	jww.INFO.Printf("using branch %v (%v)", ref, hash)
+	err = r.Storer.SetReference(plumbing.NewHashReference(branch, hash))
+
+	if err != nil {
+		return hash, err
+	}
+
+	return hash, r.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, branch))
+}
@@ -0,0 +1,305 @@
+// Copyright © 2018 Steve Huff <steve.huff@asics.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package jar
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+	jww "github.com/spf13/jwalterweatherman"
+	"github.com/spf13/viper"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/http"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/ssh"
+)
+
+// scpURLPattern matches scp-like git URLs such as git@github.com:org/repo.git.
+var scpURLPattern = regexp.MustCompile(`^(?:([^@/]+)@)?([^:/]+):`)
+
+// PassphraseFunc asks the user for a secret, such as the passphrase of an SSH
+// key.
+type PassphraseFunc func(prompt string) (string, error)
+
+// RepositoryAuth is declared under "auth" for a repository, and says how to
+// authenticate to it.  Without any of these, go-git's defaults are used: the
+// SSH agent and ~/.ssh/known_hosts for SSH URLs, and no credentials for
+// HTTPS URLs.
+type RepositoryAuth struct {
+	SSHUser          string   `mapstructure:"ssh_user"`
+	SSHAgent         bool     `mapstructure:"ssh_agent"`
+	SSHKey           string   `mapstructure:"ssh_key"`
+	PassphraseEnv    string   `mapstructure:"passphrase_env"`
+	KnownHosts       []string `mapstructure:"known_hosts"`
+	Username         string   `mapstructure:"username"`
+	PasswordEnv      string   `mapstructure:"password_env"`
+	CredentialHelper bool     `mapstructure:"credential_helper"`
+}
+
+// IsSSHURL reports whether a repository URL uses SSH.
+func IsSSHURL(rawURL string) bool {
+	if strings.HasPrefix(rawURL, "ssh://") || strings.HasPrefix(rawURL, "git+ssh://") {
+		return true
+	}
+
+	return !strings.Contains(rawURL, "://") && scpURLPattern.MatchString(rawURL)
+}
+
+// IsHTTPURL reports whether a repository URL uses HTTP or HTTPS.
+func IsHTTPURL(rawURL string) bool {
+	return strings.HasPrefix(rawURL, "https://") || strings.HasPrefix(rawURL, "http://")
+}
+
+// validate returns the problems with a repository's authentication settings.
+func (a RepositoryAuth) validate(r Repository) []string {
+	var problems []string
+	usesSSH := len(a.SSHUser) > 0 || a.SSHAgent || len(a.SSHKey) > 0 || len(a.PassphraseEnv) > 0 || len(a.KnownHosts) > 0
+	usesHTTP := len(a.Username) > 0 || len(a.PasswordEnv) > 0 || a.CredentialHelper
+
+	if usesSSH && !IsSSHURL(r.URL) {
+		problems = append(problems, fmt.Sprintf("repository %v has SSH authentication settings but %v is not an SSH URL", r.Name, r.URL))
+	}
+
+	if usesHTTP && !IsHTTPURL(r.URL) {
+		problems = append(problems, fmt.Sprintf("repository %v has HTTPS authentication settings but %v is not an HTTPS URL", r.Name, r.URL))
+	}
+
+	if a.SSHAgent && len(a.SSHKey) > 0 {
+		problems = append(problems, fmt.Sprintf("repository %v may use either ssh_agent or ssh_key, not both", r.Name))
+	}
+
+	if len(a.PasswordEnv) > 0 && a.CredentialHelper {
+		problems = append(problems, fmt.Sprintf("repository %v may use either password_env or credential_helper, not both", r.Name))
+	}
+
+	return problems
+}
+
+// AuthMethod returns the credentials used to fetch a repository, or nil to
+// use go-git's defaults.  passphrase is used to ask for the passphrase of an
+// encrypted SSH key if it isn't in the environment; it may be nil.
+func (r Repository) AuthMethod(passphrase PassphraseFunc) (transport.AuthMethod, error) {
+	a := r.Auth
+
+	switch {
+	case IsSSHURL(r.URL):
+		return a.sshAuth(r.URL, passphrase)
+	case IsHTTPURL(r.URL):
+		return a.httpAuth(r.URL)
+	default:
+		return nil, nil
+	}
+}
+
+func (a RepositoryAuth) sshAuth(rawURL string, passphrase PassphraseFunc) (transport.AuthMethod, error) {
+	user := a.SSHUser
+
+	if len(user) == 0 {
+		user = sshURLUser(rawURL)
+	}
+
+	var hostKeys ssh.HostKeyCallbackHelper
+
+	if len(a.KnownHosts) > 0 {
+		files := make([]string, len(a.KnownHosts))
+
+		for i := range a.KnownHosts {
+			files[i] = expandPath(a.KnownHosts[i])
+		}
+
+		callback, err := ssh.NewKnownHostsCallback(files...)
+
+		if err != nil {
+			return nil, fmt.Errorf("unable to read known hosts: %v", err)
+		}
+
+		hostKeys.HostKeyCallback = callback
+	}
+
+	if len(a.SSHKey) > 0 {
+		keyFile := expandPath(a.SSHKey)
+		secret := ""
+
+		if len(a.PassphraseEnv) > 0 {
+			secret = os.Getenv(a.PassphraseEnv)
+		}
+
+		keys, err := ssh.NewPublicKeysFromFile(user, keyFile, secret)
+
+		if err != nil && len(secret) == 0 && isEncryptedKeyError(err) {
+			if passphrase == nil {
+				return nil, fmt.Errorf("%v is encrypted; use passphrase_env to give its passphrase, or run masonjar in a terminal", keyFile)
+			}
+
+			secret, err = passphrase(fmt.Sprintf("Passphrase for %v", keyFile))
+
+			if err != nil {
+				return nil, err
+			}
+
+			keys, err = ssh.NewPublicKeysFromFile(user, keyFile, secret)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("unable to read SSH key %v: %v", keyFile, err)
+		}
+
+		jww.DEBUG.Printf("using SSH key %v as %v", keyFile, user)
+		keys.HostKeyCallbackHelper = hostKeys
+		return keys, nil
+	}
+
+	if a.SSHAgent || len(a.SSHUser) > 0 || len(a.KnownHosts) > 0 {
+		agent, err := ssh.NewSSHAgentAuth(user)
+
+		if err != nil {
+			return nil, fmt.Errorf("unable to use the SSH agent: %v", err)
+		}
+
+		jww.DEBUG.Printf("using the SSH agent as %v", user)
+		agent.HostKeyCallbackHelper = hostKeys
+		return agent, nil
+	}
+
+	return nil, nil
+}
+
+func (a RepositoryAuth) httpAuth(rawURL string) (transport.AuthMethod, error) {
+	username := a.Username
+
+	if u, err := url.Parse(rawURL); err == nil && u.User != nil && len(username) == 0 {
+		username = u.User.Username()
+	}
+
+	switch {
+	case len(a.PasswordEnv) > 0:
+		password := os.Getenv(a.PasswordEnv)
+
+		if len(password) == 0 {
+			return nil, fmt.Errorf("environment variable %v is not set", a.PasswordEnv)
+		}
+
+		// most hosts accept a token as the password for any user name
+		if len(username) == 0 {
+			username = "git"
+		}
+
+		return &http.BasicAuth{Username: username, Password: password}, nil
+	case a.CredentialHelper:
+		return credentialFill(rawURL, username)
+	default:
+		return nil, nil
+	}
+}
+
+// credentialFill asks git's credential helpers for a user name and password.
+func credentialFill(rawURL string, username string) (transport.AuthMethod, error) {
+	u, err := url.Parse(rawURL)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var input bytes.Buffer
+	fmt.Fprintf(&input, "protocol=%v\nhost=%v\npath=%v\n", u.Scheme, u.Host, strings.TrimPrefix(u.Path, "/"))
+
+	if len(username) > 0 {
+		fmt.Fprintf(&input, "username=%v\n", username)
+	}
+
+	input.WriteString("\n")
+
+	cmd := exec.Command("git", "credential", "fill")
+	cmd.Stdin = &input
+
+	if viper.GetBool("NoInput") {
+		cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	}
+
+	cmd.Stderr = os.Stderr
+	output, err := cmd.Output()
+
+	if err != nil {
+		return nil, fmt.Errorf("git credential fill failed: %v", err)
+	}
+
+	auth := &http.BasicAuth{}
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), "=", 2)
+
+		if len(parts) != 2 {
+			continue
+		}
+
+		switch parts[0] {
+		case "username":
+			auth.Username = parts[1]
+		case "password":
+			auth.Password = parts[1]
+		}
+	}
+
+	if len(auth.Password) == 0 {
+		return nil, fmt.Errorf("no credentials found for %v://%v", u.Scheme, u.Host)
+	}
+
+	jww.DEBUG.Printf("using credentials for %v from git's credential helper", u.Host)
+	return auth, nil
+}
+
+func sshURLUser(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil && strings.Contains(rawURL, "://") {
+		if u.User != nil && len(u.User.Username()) > 0 {
+			return u.User.Username()
+		}
+
+		return "git"
+	}
+
+	if matches := scpURLPattern.FindStringSubmatch(rawURL); len(matches) > 1 && len(matches[1]) > 0 {
+		return matches[1]
+	}
+
+	return "git"
+}
+
+func isEncryptedKeyError(err error) bool {
+	message := err.Error()
+	return strings.Contains(message, "encrypted") || strings.Contains(message, "passphrase") || strings.Contains(message, "password")
+}
+
+func expandPath(path string) string {
+	expanded, err := homedir.Expand(path)
+
+	if err != nil {
+		return path
+	}
+
+	return expanded
+}
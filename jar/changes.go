@@ -0,0 +1,280 @@
+// Copyright © 2018 Steve Huff <steve.huff@asics.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package jar
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+const (
+	JarAdded    = "added"
+	JarModified = "modified"
+	JarRemoved  = "removed"
+	JarInvalid  = "invalid"
+)
+
+// CatalogChanges describes how the jars in a repository changed between two
+// commits.
+type CatalogChanges struct {
+	Repository string          `json:"repository" yaml:"repository"`
+	From       string          `json:"from,omitempty" yaml:"from,omitempty"`
+	To         string          `json:"to" yaml:"to"`
+	Commits    []CommitSummary `json:"commits,omitempty" yaml:"commits,omitempty"`
+	Jars       []JarChange     `json:"jars,omitempty" yaml:"jars,omitempty"`
+}
+
+// CommitSummary identifies a commit by its hash, date and the first line of
+// its message.
+type CommitSummary struct {
+	Hash    string    `json:"hash" yaml:"hash"`
+	Date    time.Time `json:"date" yaml:"date"`
+	Subject string    `json:"subject" yaml:"subject"`
+}
+
+func summarizeCommit(commit *object.Commit) CommitSummary {
+	subject := strings.SplitN(strings.TrimSpace(commit.Message), "\n", 2)[0]
+	return CommitSummary{Hash: commit.Hash.String(), Date: commit.Committer.When, Subject: subject}
+}
+
+// JarChange describes a jar which was added, modified or removed, or whose
+// metadata can no longer be parsed.  Files lists the changed files of a
+// modified or invalid jar, relative to the jar, and Error why an invalid jar
+// can't be parsed.
+type JarChange struct {
+	Name   string   `json:"name" yaml:"name"`
+	Change string   `json:"change" yaml:"change"`
+	Files  []string `json:"files,omitempty" yaml:"files,omitempty"`
+	Error  string   `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// DiffCatalog compares the jars in a repository at two commits.  If from is
+// the zero hash, or is no longer in the repository, every jar is reported as
+// added.
+func DiffCatalog(repo *Repository, from plumbing.Hash, to plumbing.Hash) (*CatalogChanges, error) {
+	r, err := git.PlainOpen(repo.Dir)
+
+	if err != nil {
+		return nil, err
+	}
+
+	changes := &CatalogChanges{Repository: repo.Label(), To: to.String()}
+
+	if from == to {
+		changes.From = from.String()
+		return changes, nil
+	}
+
+	after, afterInvalid, toTree, err := catalogAt(r, repo, to)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var before map[string]bool
+	var beforeInvalid map[string]string
+	var fromTree *object.Tree
+
+	if !from.IsZero() {
+		before, beforeInvalid, fromTree, err = catalogAt(r, repo, from)
+
+		if err != nil {
+			before, beforeInvalid, fromTree = nil, nil, nil
+		}
+	}
+
+	if fromTree == nil {
+		for name := range after {
+			changes.Jars = append(changes.Jars, JarChange{Name: name, Change: JarAdded})
+		}
+
+		for name, reason := range afterInvalid {
+			changes.Jars = append(changes.Jars, JarChange{Name: name, Change: JarInvalid, Error: reason})
+		}
+
+		sortJarChanges(changes.Jars)
+		return changes, nil
+	}
+
+	changes.From = from.String()
+	commits, err := CommitsBetween(r, from, to)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, commit := range commits {
+		changes.Commits = append(changes.Commits, summarizeCommit(commit))
+	}
+
+	diff, err := object.DiffTree(fromTree, toTree)
+
+	if err != nil {
+		return nil, err
+	}
+
+	existedBefore := func(name string) bool {
+		_, ok := beforeInvalid[name]
+		return before[name] || ok
+	}
+
+	existsAfter := func(name string) bool {
+		_, ok := afterInvalid[name]
+		return after[name] || ok
+	}
+
+	catalogs := []map[string]bool{before, after, invalidNames(beforeInvalid), invalidNames(afterInvalid)}
+	modified := map[string][]string{}
+
+	for _, change := range diff {
+		path := change.To.Name
+
+		if len(path) == 0 {
+			path = change.From.Name
+		}
+
+		name := jarContaining(path, catalogs...)
+
+		if existedBefore(name) && existsAfter(name) {
+			modified[name] = append(modified[name], strings.TrimPrefix(path, name+"/"))
+		}
+	}
+
+	for name := range after {
+		if !existedBefore(name) {
+			changes.Jars = append(changes.Jars, JarChange{Name: name, Change: JarAdded})
+		}
+	}
+
+	for name := range before {
+		if !existsAfter(name) {
+			changes.Jars = append(changes.Jars, JarChange{Name: name, Change: JarRemoved})
+		}
+	}
+
+	for name := range beforeInvalid {
+		if !existsAfter(name) {
+			changes.Jars = append(changes.Jars, JarChange{Name: name, Change: JarRemoved})
+		}
+	}
+
+	// a jar which was already invalid is only reported again if it changed
+	for name, reason := range afterInvalid {
+		if _, ok := beforeInvalid[name]; ok && len(modified[name]) == 0 {
+			continue
+		}
+
+		files := modified[name]
+		sort.Strings(files)
+		delete(modified, name)
+		changes.Jars = append(changes.Jars, JarChange{Name: name, Change: JarInvalid, Files: files, Error: reason})
+	}
+
+	for name, files := range modified {
+		sort.Strings(files)
+		changes.Jars = append(changes.Jars, JarChange{Name: name, Change: JarModified, Files: files})
+	}
+
+	sortJarChanges(changes.Jars)
+	return changes, nil
+}
+
+// catalogAt returns the names of the jars at a commit, the reasons the
+// invalid jars at the commit can't be parsed, keyed by their names, and the
+// commit's tree.
+func catalogAt(r *git.Repository, repo *Repository, hash plumbing.Hash) (map[string]bool, map[string]string, *object.Tree, error) {
+	commit, err := r.CommitObject(hash)
+
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	tree, err := commit.Tree()
+
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	jars, invalid, err := commitJars(r, repo, hash)
+
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	names := map[string]bool{}
+
+	for i := range jars {
+		names[jars[i].Name()] = true
+	}
+
+	reasons := map[string]string{}
+
+	for i := range invalid {
+		// invalid jars are named along with their repository
+		name := invalid[i].Name
+
+		if len(repo.Name) > 0 {
+			name = strings.TrimPrefix(name, repo.Name+"/")
+		}
+
+		reasons[name] = invalid[i].Error
+	}
+
+	return names, reasons, tree, nil
+}
+
+// invalidNames returns the names of the invalid jars returned by catalogAt.
+func invalidNames(invalid map[string]string) map[string]bool {
+	names := map[string]bool{}
+
+	for name := range invalid {
+		names[name] = true
+	}
+
+	return names
+}
+
+// jarContaining returns the name of the jar whose directory contains path, or
+// an empty string if it isn't part of a jar.
+func jarContaining(path string, catalogs ...map[string]bool) string {
+	found := ""
+
+	for _, catalog := range catalogs {
+		for name := range catalog {
+			if strings.HasPrefix(path, name+"/") && len(name) > len(found) {
+				found = name
+			}
+		}
+	}
+
+	return found
+}
+
+func sortJarChanges(changes []JarChange) {
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Name < changes[j].Name
+	})
+}
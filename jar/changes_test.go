@@ -0,0 +1,145 @@
+// Copyright © 2018 Steve Huff <steve.huff@asics.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package jar
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+func TestDiffCatalog(t *testing.T) {
+	r, dir := testGitRepo(t)
+	defer os.RemoveAll(dir)
+
+	repo := &Repository{Name: "platform", Dir: dir}
+
+	first := commitFiles(t, r, dir, "Add jars", map[string]string{
+		"base/metadata.yaml":   "prefix: base-",
+		"base/LICENSE":         "MIT",
+		"child/metadata.yaml":  "prefix: child-",
+		"broken/metadata.yaml": "prefix: broken-",
+	})
+
+	second := commitFiles(t, r, dir, "Change jars\n\nWith a body.", map[string]string{
+		"base/LICENSE":         "BSD",
+		"child/metadata.yaml":  "",
+		"newjar/metadata.yaml": "prefix: new-",
+		"broken/metadata.yaml": "prefix: [",
+	})
+
+	third := commitFiles(t, r, dir, "Change the license again", map[string]string{
+		"base/LICENSE": "ISC",
+	})
+
+	tests := []struct {
+		name        string
+		from        plumbing.Hash
+		to          plumbing.Hash
+		wantCommits []string
+		want        []JarChange
+	}{
+		{
+			name: "every jar is added by a new clone",
+			from: plumbing.ZeroHash,
+			to:   first,
+			want: []JarChange{
+				{Name: "base", Change: JarAdded},
+				{Name: "broken", Change: JarAdded},
+				{Name: "child", Change: JarAdded},
+			},
+		},
+		{
+			name:        "jars are added, modified, removed and broken",
+			from:        first,
+			to:          second,
+			wantCommits: []string{"Change jars"},
+			want: []JarChange{
+				{Name: "base", Change: JarModified, Files: []string{"LICENSE"}},
+				{Name: "broken", Change: JarInvalid, Files: []string{"metadata.yaml"}},
+				{Name: "child", Change: JarRemoved},
+				{Name: "newjar", Change: JarAdded},
+			},
+		},
+		{
+			name:        "a jar which is still broken is not reported again",
+			from:        second,
+			to:          third,
+			wantCommits: []string{"Change the license again"},
+			want: []JarChange{
+				{Name: "base", Change: JarModified, Files: []string{"LICENSE"}},
+			},
+		},
+		{
+			name:        "commits are listed newest first",
+			from:        first,
+			to:          third,
+			wantCommits: []string{"Change the license again", "Change jars"},
+			want: []JarChange{
+				{Name: "base", Change: JarModified, Files: []string{"LICENSE"}},
+				{Name: "broken", Change: JarInvalid, Files: []string{"metadata.yaml"}},
+				{Name: "child", Change: JarRemoved},
+				{Name: "newjar", Change: JarAdded},
+			},
+		},
+		{
+			name: "nothing changes between a commit and itself",
+			from: third,
+			to:   third,
+		},
+	}
+
+	for _, test := range tests {
+		changes, err := DiffCatalog(repo, test.from, test.to)
+
+		if err != nil {
+			t.Errorf("%v: %v", test.name, err)
+			continue
+		}
+
+		var subjects []string
+
+		for _, commit := range changes.Commits {
+			subjects = append(subjects, commit.Subject)
+		}
+
+		if !reflect.DeepEqual(subjects, test.wantCommits) {
+			t.Errorf("%v: got commits %q, want %q", test.name, subjects, test.wantCommits)
+		}
+
+		for i := range changes.Jars {
+			if changes.Jars[i].Change == JarInvalid {
+				if !strings.Contains(changes.Jars[i].Error, "invalid metadata") {
+					t.Errorf("%v: got error %q for invalid jar %v", test.name, changes.Jars[i].Error, changes.Jars[i].Name)
+				}
+
+				changes.Jars[i].Error = ""
+			}
+		}
+
+		if !reflect.DeepEqual(changes.Jars, test.want) {
+			t.Errorf("%v: got changes %+v, want %+v", test.name, changes.Jars, test.want)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+// Copyright © 2018 Steve Huff <steve.huff@asics.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package jar
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	jww "github.com/spf13/jwalterweatherman"
+	"github.com/spf13/viper"
+)
+
+// layerPlan describes how one layer of a jar is written to the destination.
+type layerPlan struct {
+	jar      Jar
+	top      Jar
+	metadata *viper.Viper
+	paths    map[string]string
+	dirs     map[string]bool
+	excluded []string
+}
+
+// ComposeJars combines jars which are opened together into a single jar
+// whose metadata is merged in the same way as for a jar which extends
+// another, with later jars taking precedence.  A single jar is returned as
+// is.
+func ComposeJars(jars []Jar) (Jar, error) {
+	if len(jars) == 0 {
+		return nil, fmt.Errorf("no jars to open")
+	}
+
+	if len(jars) == 1 {
+		return jars[0], nil
+	}
+
+	names := []string{jars[0].Name()}
+	metadata := jars[0].Metadata()
+
+	for i := 1; i < len(jars); i++ {
+		var err error
+		metadata, err = mergeMetadata(metadata, jars[i].Metadata())
+
+		if err != nil {
+			return nil, fmt.Errorf("unable to merge metadata from %v: %v", jars[i].Name(), err)
+		}
+
+		names = append(names, jars[i].Name())
+	}
+
+	return &MasonJar{name: strings.Join(names, "+"), metadata: metadata, own: metadata}, nil
+}
+
+// planLayers renders the destination paths of every layer of the jars opened
+// as name.  A jar extended by more than one of the jars is only laid down
+// once, without the paths deleted on the way to the last jar which includes it.
+func planLayers(name string, jars []Jar, data TemplateData, excluded []string) ([]layerPlan, error) {
+	var plans []layerPlan
+	planned := map[string]bool{}
+	deleted := deletedByChildren(jars)
+
+	for _, top := range jars {
+		layers := Layers(top)
+
+		for i := range layers {
+			if planned[layers[i].Path()] {
+				jww.DEBUG.Printf("jar %v is already being laid down", QualifiedName(layers[i]))
+				continue
+			}
+
+			planned[layers[i].Path()] = true
+
+			// each layer loses the paths deleted by the layers which extend it
+			layerExcluded := append(append([]string{}, excluded...), deleted[layers[i].Path()]...)
+
+			paths, err := RenderPaths(layers[i], data, layerExcluded)
+
+			if err != nil {
+				return nil, err
+			}
+
+			dirs, err := jarDirs(layers[i])
+
+			if err != nil {
+				return nil, err
+			}
+
+			plans = append(plans, layerPlan{
+				jar:      layers[i],
+				top:      top,
+				metadata: top.Metadata(),
+				paths:    paths,
+				dirs:     dirs,
+				excluded: layerExcluded,
+			})
+		}
+	}
+
+	return plans, resolveOverrides(name, plans)
+}
+
+// deletedByChildren returns the delete patterns of the layers which extend
+// each layer of jars, keyed by the path of the extended layer.  A layer
+// shared by several of the jars loses the paths deleted by the last of them,
+// so that a later jar which keeps a file wins over an earlier one which
+// deletes it.
+func deletedByChildren(jars []Jar) map[string][]string {
+	deleted := map[string][]string{}
+
+	for _, top := range jars {
+		layers := Layers(top)
+
+		for i := range layers {
+			var patterns []string
+
+			for _, child := range layers[i+1:] {
+				patterns = append(patterns, DeletedPatterns(child)...)
+			}
+
+			deleted[layers[i].Path()] = patterns
+		}
+	}
+
+	return deleted
+}
+
+// resolveOverrides makes sure that each destination file is written by only
+// the last layer which provides it.  Files replaced by a jar which does not
+// extend the jar providing them are reported, since that is probably not what
+// the author of either jar intended.
+func resolveOverrides(name string, plans []layerPlan) error {
+	type provider struct {
+		plan int
+		src  string
+	}
+
+	providers := map[string]provider{}
+	var conflicts, problems []string
+
+	for i := range plans {
+		for src, dest := range plans[i].paths {
+			prev, ok := providers[dest]
+
+			if !ok {
+				providers[dest] = provider{plan: i, src: src}
+				continue
+			}
+
+			prevPlan := plans[prev.plan]
+			prevDir := prevPlan.dirs[prev.src]
+			dir := plans[i].dirs[src]
+
+			if prevDir && dir {
+				continue
+			}
+
+			if prevDir != dir {
+				problems = append(problems, fmt.Sprintf("%v is a %v in %v but a %v in %v", strings.TrimPrefix(dest, "/"), pathKind(prevDir), QualifiedName(prevPlan.jar), pathKind(dir), QualifiedName(plans[i].jar)))
+				continue
+			}
+
+			delete(prevPlan.paths, prev.src)
+			providers[dest] = provider{plan: i, src: src}
+
+			if !extends(plans[i].top, prevPlan.jar) {
+				conflicts = append(conflicts, fmt.Sprintf("%v: %v replaces %v", strings.TrimPrefix(dest, "/"), QualifiedName(plans[i].jar), QualifiedName(prevPlan.jar)))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return &ValidationError{Jar: name, What: "conflicting paths", Problems: problems}
+	}
+
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		Warn("jars provide the same files; later jars take precedence:\n  - %v", strings.Join(conflicts, "\n  - "))
+	}
+
+	return nil
+}
+
+// extends reports whether j is, or extends, ancestor.
+func extends(j Jar, ancestor Jar) bool {
+	for _, layer := range Layers(j) {
+		if layer.Path() == ancestor.Path() {
+			return true
+		}
+	}
+
+	return false
+}
+
+func jarDirs(j Jar) (map[string]bool, error) {
+	dirs := map[string]bool{}
+
+	err := j.Walk(func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.IsDir() {
+			dirs[path] = true
+		}
+
+		return nil
+	})
+
+	return dirs, err
+}
+
+func pathKind(dir bool) string {
+	if dir {
+		return "directory"
+	}
+
+	return "file"
+}
@@ -0,0 +1,102 @@
+// Copyright © 2018 Steve Huff <steve.huff@asics.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package jar
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestPlanLayersSharedParent(t *testing.T) {
+	base := memJar(t, "base", "prefix: base-", "/README.md", "/Dockerfile", "/Makefile")
+	lambda := memJar(t, "lambda", "extends: base\ndelete: [Dockerfile]", "/handler.go")
+	worker := memJar(t, "worker", "extends: base\ndelete: [Makefile]", "/worker.go")
+	slim := memJar(t, "slim", "extends: base\ndelete: [Dockerfile]", "/slim.go")
+
+	if _, invalid := resolveParents([]Jar{base, lambda, worker, slim}); len(invalid) > 0 {
+		t.Fatal(invalid)
+	}
+
+	tests := []struct {
+		name       string
+		jars       []Jar
+		wantLayers []string
+		wantBase   []string
+	}{
+		{
+			name:       "the last jar keeps the file an earlier jar deletes",
+			jars:       []Jar{lambda, worker},
+			wantLayers: []string{"base", "lambda", "worker"},
+			wantBase:   []string{"/Dockerfile", "/README.md"},
+		},
+		{
+			name:       "the order of the jars decides",
+			jars:       []Jar{worker, lambda},
+			wantLayers: []string{"base", "worker", "lambda"},
+			wantBase:   []string{"/Makefile", "/README.md"},
+		},
+		{
+			name:       "jars which agree both delete the file",
+			jars:       []Jar{lambda, slim},
+			wantLayers: []string{"base", "lambda", "slim"},
+			wantBase:   []string{"/Makefile", "/README.md"},
+		},
+		{
+			name:       "the parent given last keeps everything",
+			jars:       []Jar{lambda, base},
+			wantLayers: []string{"base", "lambda"},
+			wantBase:   []string{"/Dockerfile", "/Makefile", "/README.md"},
+		},
+	}
+
+	for _, test := range tests {
+		plans, err := planLayers("composed", test.jars, TemplateData{"Identifier": "x"}, nil)
+
+		if err != nil {
+			t.Errorf("%v: %v", test.name, err)
+			continue
+		}
+
+		var layers []string
+		var basePaths []string
+
+		for _, plan := range plans {
+			layers = append(layers, plan.jar.Name())
+
+			if plan.jar == Jar(base) {
+				for src := range plan.paths {
+					basePaths = append(basePaths, src)
+				}
+			}
+		}
+
+		if !reflect.DeepEqual(layers, test.wantLayers) {
+			t.Errorf("%v: got layers %v, want %v", test.name, layers, test.wantLayers)
+		}
+
+		sort.Strings(basePaths)
+
+		if !reflect.DeepEqual(basePaths, test.wantBase) {
+			t.Errorf("%v: got shared parent paths %v, want %v", test.name, basePaths, test.wantBase)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright © 2018 Steve Huff <steve.huff@asics.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package jar
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	jww "github.com/spf13/jwalterweatherman"
+	"github.com/spf13/viper"
+)
+
+// Condition is declared under "conditions" in a jar's metadata.  The paths
+// it matches are only written when its expression is true.
+type Condition struct {
+	Paths []string `mapstructure:"paths"`
+	When  string   `mapstructure:"when"`
+}
+
+// ParseConditions decodes and checks the conditions in a jar's metadata.
+func ParseConditions(metadata *viper.Viper) ([]Condition, error) {
+	var conditions []Condition
+
+	if metadata == nil || !metadata.IsSet("conditions") {
+		return conditions, nil
+	}
+
+	err := metadata.UnmarshalKey("conditions", &conditions)
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse conditions: %v", err)
+	}
+
+	var problems []string
+
+	for i := range conditions {
+		c := conditions[i]
+
+		if len(c.Paths) == 0 {
+			problems = append(problems, fmt.Sprintf("condition #%v has no paths", i+1))
+		}
+
+		for _, pattern := range c.Paths {
+			if _, err := filepath.Match(normalizePattern(pattern), ""); err != nil {
+				problems = append(problems, fmt.Sprintf("condition #%v has invalid path '%v': %v", i+1, pattern, err))
+			}
+		}
+
+		if len(strings.TrimSpace(c.When)) == 0 {
+			problems = append(problems, fmt.Sprintf("condition #%v has no expression", i+1))
+		} else if _, err := c.template(); err != nil {
+			problems = append(problems, fmt.Sprintf("condition #%v has invalid expression: %v", i+1, err))
+		}
+	}
+
+	if len(problems) > 0 {
+		return conditions, fmt.Errorf("invalid conditions:\n  - %v", strings.Join(problems, "\n  - "))
+	}
+
+	return conditions, nil
+}
+
+// Evaluate reports whether the condition's expression is true for data,
+// using the same rules as a template's "if" action.
+func (c Condition) Evaluate(data TemplateData) (bool, error) {
+	tmpl, err := c.template()
+
+	if err != nil {
+		return false, err
+	}
+
+	var result bytes.Buffer
+	err = tmpl.Execute(&result, data)
+
+	if err != nil {
+		return false, err
+	}
+
+	return result.String() == "true", nil
+}
+
+func (c Condition) template() (*template.Template, error) {
+	expression := strings.TrimSpace(c.When)
+	expression = strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(expression, "{{"), "}}"))
+
+	return template.New("condition").Option("missingkey=error").Parse(fmt.Sprintf("{{ if %v }}true{{ end }}", expression))
+}
+
+// ExcludedPatterns evaluates a jar's conditions and returns the path patterns
+// which must not be written.
+func ExcludedPatterns(metadata *viper.Viper, data TemplateData) ([]string, error) {
+	conditions, err := ParseConditions(metadata)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var excluded []string
+
+	for i := range conditions {
+		c := conditions[i]
+		include, err := c.Evaluate(data)
+
+		if err != nil {
+			return nil, fmt.Errorf("unable to evaluate condition '%v': %v", c.When, err)
+		}
+
+		if !include {
+			jww.INFO.Printf("excluding %v because '%v' is false", c.Paths, c.When)
+			excluded = append(excluded, c.Paths...)
+		}
+	}
+
+	return excluded, nil
+}
+
+// IsExcluded reports whether a jar path matches one of the excluded patterns.
+// Patterns without a "/" match the name of a file or directory at any depth;
+// other patterns match the path relative to the root of the jar.  Excluding
+// a directory excludes everything beneath it.
+func IsExcluded(path string, patterns []string) bool {
+	relPath := strings.TrimPrefix(filepath.ToSlash(path), "/")
+
+	for _, pattern := range patterns {
+		pattern = normalizePattern(pattern)
+		segments := strings.Split(relPath, "/")
+
+		for i := range segments {
+			var candidate string
+
+			if strings.Contains(pattern, "/") {
+				candidate = strings.Join(segments[:i+1], "/")
+			} else {
+				candidate = segments[i]
+			}
+
+			if matched, _ := filepath.Match(pattern, candidate); matched {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func normalizePattern(pattern string) string {
+	return strings.Trim(filepath.ToSlash(strings.TrimSpace(pattern)), "/")
+}
@@ -0,0 +1,120 @@
+// Copyright © 2018 Steve Huff <steve.huff@asics.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package jar
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIsExcluded(t *testing.T) {
+	tests := []struct {
+		path     string
+		patterns []string
+		want     bool
+	}{
+		{"/terraform/main.tf", []string{"terraform"}, true},
+		{"/terraform", []string{"terraform"}, true},
+		{"/deploy/terraform/main.tf", []string{"terraform"}, true},
+		{"/terraform-docs/README.md", []string{"terraform"}, false},
+		{"/main.tf", []string{"*.tf"}, true},
+		{"/modules/vpc/main.tf", []string{"*.tf"}, true},
+		{"/main.tfvars", []string{"*.tf"}, false},
+		{"/deploy/canary.yaml", []string{"deploy/canary.yaml"}, true},
+		{"/other/deploy/canary.yaml", []string{"deploy/canary.yaml"}, false},
+		{"/deploy/k8s/service.yaml", []string{"deploy/k8s"}, true},
+		{"/deploy/k8s/service.yaml", []string{"/deploy/k8s/"}, true},
+		{"/deploy/k8s/service.yaml", []string{" deploy/* "}, true},
+		{"/README.md", []string{"docs", "*.tf"}, false},
+		{"/README.md", nil, false},
+	}
+
+	for _, test := range tests {
+		if got := IsExcluded(test.path, test.patterns); got != test.want {
+			t.Errorf("IsExcluded(%q, %q) = %v, want %v", test.path, test.patterns, got, test.want)
+		}
+	}
+}
+
+func TestParseConditionsReportsEveryProblem(t *testing.T) {
+	metadata := testMetadata(t, `
+conditions:
+  - when: .use_terraform
+  - paths: ["[", docs]
+    when: .docs
+  - paths: [x]
+  - paths: [y]
+    when: ".a )"
+`)
+
+	_, err := ParseConditions(metadata)
+
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+
+	for _, problem := range []string{
+		"condition #1 has no paths",
+		"condition #2 has invalid path '['",
+		"condition #3 has no expression",
+		"condition #4 has invalid expression",
+	} {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("expected %q in:\n%v", problem, err)
+		}
+	}
+}
+
+func TestExcludedPatterns(t *testing.T) {
+	metadata := testMetadata(t, `
+conditions:
+  - paths: [terraform, "*.tf"]
+    when: .use_terraform
+  - paths: [deploy/canary.yaml]
+    when: "{{ and (eq .tier \"web\") (gt .replicas 3) }}"
+  - paths: [docs]
+    when: not .skip_docs
+`)
+
+	tests := []struct {
+		data TemplateData
+		want []string
+	}{
+		{TemplateData{"use_terraform": true, "tier": "web", "replicas": 4, "skip_docs": false}, nil},
+		{TemplateData{"use_terraform": false, "tier": "web", "replicas": 2, "skip_docs": true}, []string{"terraform", "*.tf", "deploy/canary.yaml", "docs"}},
+		{TemplateData{"use_terraform": "", "tier": "worker", "replicas": 4, "skip_docs": false}, []string{"terraform", "*.tf", "deploy/canary.yaml"}},
+	}
+
+	for _, test := range tests {
+		got, err := ExcludedPatterns(metadata, test.data)
+
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", test.data, err)
+		} else if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: got %q, want %q", test.data, got, test.want)
+		}
+	}
+
+	if _, err := ExcludedPatterns(metadata, TemplateData{"tier": "web", "replicas": 1}); err == nil {
+		t.Error("expected an error for a missing value")
+	}
+}
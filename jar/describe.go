@@ -0,0 +1,217 @@
+// Copyright © 2018 Steve Huff <steve.huff@asics.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package jar
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+// Summary identifies a jar and what it is for, as shown by "masonjar list".
+type Summary struct {
+	Name            string   `json:"name" yaml:"name"`
+	Repository      string   `json:"repository,omitempty" yaml:"repository,omitempty"`
+	Description     string   `json:"description,omitempty" yaml:"description,omitempty"`
+	Tags            []string `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Deprecated      bool     `json:"deprecated" yaml:"deprecated"`
+	DeprecationNote string   `json:"deprecation_note,omitempty" yaml:"deprecation_note,omitempty"`
+}
+
+// Summarize returns a jar's summary.
+func Summarize(j Jar) Summary {
+	deprecated, note := Deprecation(j)
+
+	return Summary{
+		Name:            QualifiedName(j),
+		Repository:      jarRepositoryLabel(j),
+		Description:     strings.TrimSpace(j.Metadata().GetString("description")),
+		Tags:            j.Metadata().GetStringSlice("tags"),
+		Deprecated:      deprecated,
+		DeprecationNote: note,
+	}
+}
+
+// Deprecation reports whether a jar's own metadata marks it as deprecated.
+// "deprecated" is either true or a note, such as which jar to use instead.
+func Deprecation(j Jar) (bool, string) {
+	switch value := j.OwnMetadata().Get("deprecated").(type) {
+	case bool:
+		return value, ""
+	case string:
+		note := strings.TrimSpace(value)
+		return len(note) > 0, note
+	default:
+		return false, ""
+	}
+}
+
+// Description describes what a jar does, as shown by "masonjar show".
+type Description struct {
+	Summary     `yaml:",inline"`
+	Extends     string            `json:"extends,omitempty" yaml:"extends,omitempty"`
+	Prefix      string            `json:"prefix" yaml:"prefix"`
+	Maintainers []string          `json:"maintainers,omitempty" yaml:"maintainers,omitempty"`
+	Variables   []Variable        `json:"variables,omitempty" yaml:"variables,omitempty"`
+	Hooks       map[string][]Hook `json:"hooks,omitempty" yaml:"hooks,omitempty"`
+	Files       []FileDescription `json:"files" yaml:"files"`
+	Readme      string            `json:"readme,omitempty" yaml:"readme,omitempty"`
+}
+
+// FileDescription describes a file which a jar lays down.
+type FileDescription struct {
+	Path     string `json:"path" yaml:"path"`
+	Template bool   `json:"template" yaml:"template"`
+
+	// From names the jar the file is inherited from, if it isn't the jar
+	// being described.
+	From string `json:"from,omitempty" yaml:"from,omitempty"`
+}
+
+// maskedSecret is shown in place of the default of a secret variable.
+const maskedSecret = "********"
+
+// readmeNames are the files shown as a jar's README, in order of preference.
+var readmeNames = []string{"README.md", "README", "README.txt"}
+
+// Describe summarizes a jar, including everything it inherits.
+func Describe(j Jar) (*Description, error) {
+	metadata := j.Metadata()
+
+	d := &Description{
+		Summary:     Summarize(j),
+		Prefix:      j.Prefix(),
+		Maintainers: metadata.GetStringSlice("maintainers"),
+		Hooks:       map[string][]Hook{},
+	}
+
+	if j.Parent() != nil {
+		d.Extends = QualifiedName(j.Parent())
+	}
+
+	variables, err := ParseVariables(metadata)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defaults := DefaultValues(metadata)
+
+	for i := range variables {
+		if value, ok := defaults.Get(variables[i].Name); ok {
+			variables[i].Default = value
+		}
+
+		variables[i].Default = normalizeValue(variables[i].Default)
+
+		// secret defaults are masked, as they are when prompting
+		if variables[i].Secret && variables[i].Default != nil {
+			variables[i].Default = maskedSecret
+		}
+	}
+
+	d.Variables = variables
+
+	for _, stage := range []string{HookStagePreOpen, HookStagePostOpen} {
+		hooks, err := ParseHooks(metadata, stage)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if len(hooks) > 0 {
+			d.Hooks[stage] = hooks
+		}
+	}
+
+	d.Files, err = describeFiles(j)
+
+	if err != nil {
+		return nil, err
+	}
+
+	d.Readme = readme(j)
+
+	return d, nil
+}
+
+// readme returns the contents of a jar's README, if it has one.
+func readme(j Jar) string {
+	for _, name := range readmeNames {
+		if text, err := afero.ReadFile(j.Fs(), "/"+name); err == nil {
+			return string(text)
+		}
+	}
+
+	return ""
+}
+
+// describeFiles lists the files a jar lays down, sorted by path, leaving out
+// files which a child deletes from its parent.
+func describeFiles(j Jar) ([]FileDescription, error) {
+	layers := Layers(j)
+	files := map[string]FileDescription{}
+
+	for i, layer := range layers {
+		var deleted []string
+
+		for _, child := range layers[i+1:] {
+			deleted = append(deleted, DeletedPatterns(child)...)
+		}
+
+		from := ""
+
+		if layer != j {
+			from = QualifiedName(layer)
+		}
+
+		paths, err := layer.Files()
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, path := range paths {
+			if IsSkippable(path) || IsExcluded(path, deleted) {
+				continue
+			}
+
+			files[path] = FileDescription{
+				Path:     strings.TrimPrefix(path, "/"),
+				Template: IsTemplate(path, j.Metadata()),
+				From:     from,
+			}
+		}
+	}
+
+	var described []FileDescription
+
+	for _, f := range files {
+		described = append(described, f)
+	}
+
+	sort.Slice(described, func(a, b int) bool {
+		return described[a].Path < described[b].Path
+	})
+
+	return described, nil
+}
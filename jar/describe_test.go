@@ -0,0 +1,106 @@
+// Copyright © 2018 Steve Huff <steve.huff@asics.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package jar
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDescribe(t *testing.T) {
+	base := memJar(t, "base", `
+prefix: base-
+maintainers: [platform]
+variables:
+  - name: region
+    default: us-east-1
+  - name: token
+    secret: true
+    default: hunter2
+hooks:
+  post_open:
+    - command: [git, init]
+`, "/README.md", "/Dockerfile", "/Makefile")
+	child := memJar(t, "child", `
+extends: base
+prefix: child-
+description: "  A child jar.  "
+tags: [go]
+deprecated: use lambda-go
+delete: [Dockerfile]
+templates:
+  main.go: {}
+variables:
+  - name: replicas
+    type: int
+    required: true
+`, "/main.go", "/README.md")
+
+	if _, invalid := resolveParents([]Jar{base, child}); len(invalid) > 0 {
+		t.Fatal(invalid)
+	}
+
+	d, err := Describe(child)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d.Name != "child" || d.Extends != "base" || d.Prefix != "child-" || d.Description != "A child jar." {
+		t.Errorf("got %+v", d.Summary)
+	}
+
+	if !d.Deprecated || d.DeprecationNote != "use lambda-go" {
+		t.Errorf("got deprecated %v with note %q", d.Deprecated, d.DeprecationNote)
+	}
+
+	if !reflect.DeepEqual(d.Maintainers, []string{"platform"}) || !reflect.DeepEqual(d.Tags, []string{"go"}) {
+		t.Errorf("got maintainers %v and tags %v", d.Maintainers, d.Tags)
+	}
+
+	defaults := map[string]interface{}{}
+
+	for _, v := range d.Variables {
+		defaults[v.Name] = v.Default
+	}
+
+	if want := map[string]interface{}{"region": "us-east-1", "token": maskedSecret, "replicas": nil}; !reflect.DeepEqual(defaults, want) {
+		t.Errorf("got defaults %v, want %v", defaults, want)
+	}
+
+	if hooks := d.Hooks[HookStagePostOpen]; len(hooks) != 1 || hooks[0].Name != "git init" {
+		t.Errorf("got hooks %+v", d.Hooks)
+	}
+
+	want := []FileDescription{
+		{Path: "Makefile", From: "base"},
+		{Path: "README.md"},
+		{Path: "main.go", Template: true},
+	}
+
+	if !reflect.DeepEqual(d.Files, want) {
+		t.Errorf("got files %+v, want %+v", d.Files, want)
+	}
+
+	if d.Readme != "/README.md" {
+		t.Errorf("got README %q, want the jar's own", d.Readme)
+	}
+}
@@ -0,0 +1,258 @@
+// Copyright © 2018 Steve Huff <steve.huff@asics.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package jar
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	jww "github.com/spf13/jwalterweatherman"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v2"
+)
+
+// Layers returns a jar and the jars it extends, starting with the jar at the
+// root of the inheritance chain.  Files are laid down in this order.
+func Layers(j Jar) []Jar {
+	var layers []Jar
+
+	for layer := j; layer != nil; layer = layer.Parent() {
+		layers = append([]Jar{layer}, layers...)
+	}
+
+	return layers
+}
+
+// DeletedPatterns returns the parent paths removed by a jar's own "delete"
+// list.
+func DeletedPatterns(j Jar) []string {
+	return j.OwnMetadata().GetStringSlice("delete")
+}
+
+// resolveParents links every jar to the jar named by its "extends" key and
+// merges inherited metadata.  Jars whose parent is missing, or which are part
+// of a cycle, are dropped with a warning and returned as invalid jars.
+func resolveParents(jars []Jar) ([]Jar, []InvalidJar) {
+	return resolveParentsWith(jars, nil)
+}
+
+// resolveParentsWith resolves the parents of jars, which may also extend
+// jars in others.  The jars in others must already be resolved.
+func resolveParentsWith(jars []Jar, others []Jar) ([]Jar, []InvalidJar) {
+	byName := map[string]*MasonJar{}
+
+	for _, list := range [][]Jar{others, jars} {
+		for i := range list {
+			if j, ok := list[i].(*MasonJar); ok {
+				byName[QualifiedName(j)] = j
+			}
+		}
+	}
+
+	var resolved []Jar
+	var invalid []InvalidJar
+
+	for i := range jars {
+		j, ok := jars[i].(*MasonJar)
+
+		if !ok {
+			resolved = append(resolved, jars[i])
+			continue
+		}
+
+		err := resolveParent(j, byName, []string{})
+
+		if err != nil {
+			jww.WARN.Printf("jar %v: %v", QualifiedName(j), err)
+			invalid = append(invalid, InvalidJar{Name: QualifiedName(j), Repository: jarRepositoryLabel(j), Error: err.Error()})
+			continue
+		}
+
+		resolved = append(resolved, j)
+	}
+
+	return resolved, invalid
+}
+
+func resolveParent(j *MasonJar, byName map[string]*MasonJar, chain []string) error {
+	name := QualifiedName(j)
+
+	for i := range chain {
+		if chain[i] == name {
+			return fmt.Errorf("jar inheritance cycle: %v", strings.Join(append(chain, name), " -> "))
+		}
+	}
+
+	parentName := j.Extends()
+
+	if len(parentName) == 0 || j.parent != nil {
+		return nil
+	}
+
+	// look in the jar's own repository first
+	parent, ok := byName[qualify(repositoryName(j), parentName)]
+
+	if !ok {
+		parent, ok = byName[parentName]
+	}
+
+	if !ok {
+		return fmt.Errorf("extends %v, which is not a valid jar", parentName)
+	}
+
+	err := resolveParent(parent, byName, append(chain, name))
+
+	if err != nil {
+		return err
+	}
+
+	metadata, err := mergeMetadata(parent.Metadata(), j.OwnMetadata())
+
+	if err != nil {
+		return fmt.Errorf("unable to merge metadata from %v: %v", parentName, err)
+	}
+
+	jww.DEBUG.Printf("jar %v extends %v", name, QualifiedName(parent))
+	j.parent = parent
+	j.metadata = metadata
+	return nil
+}
+
+// mergeMetadata overlays a child jar's metadata on its parent's.  Variables
+// are merged by name, lists of hooks, conditions and preconditions are
+// concatenated, maps are merged and anything else is replaced.
+func mergeMetadata(parent *viper.Viper, child *viper.Viper) (*viper.Viper, error) {
+	merged := metadataSettings(parent)
+	own := metadataSettings(child)
+
+	// these only ever apply to the jar which declares them
+	delete(merged, "extends")
+	delete(merged, "delete")
+
+	for key, value := range own {
+		switch key {
+		case "variables":
+			merged[key] = mergeVariables(merged[key], value)
+		case "conditions":
+			merged[key] = concatLists(merged[key], value)
+		case "hooks", "preconditions":
+			merged[key] = mergeLists(merged[key], value)
+		default:
+			ownMap, ownIsMap := value.(Values)
+			mergedMap, mergedIsMap := merged[key].(Values)
+
+			if ownIsMap && mergedIsMap {
+				merged[key] = mergedMap.Merge(ownMap)
+			} else {
+				merged[key] = value
+			}
+		}
+	}
+
+	// round-trip through a config reader so that keys containing dots, like
+	// template file names, can still be looked up
+	encoded, err := yaml.Marshal(merged.toMap())
+
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := viper.New()
+	metadata.SetConfigType("yaml")
+	err = metadata.ReadConfig(bytes.NewReader(encoded))
+
+	return metadata, err
+}
+
+// metadataSettings returns every top-level metadata setting.  AllSettings
+// alone would lose keys whose values are empty maps, which is how most
+// templates are declared.
+func metadataSettings(metadata *viper.Viper) Values {
+	settings := normalizeValue(metadata.AllSettings()).(Values)
+
+	for _, key := range []string{"templates", "values"} {
+		if metadata.IsSet(key) {
+			settings[key] = normalizeValue(metadata.Get(key))
+		}
+	}
+
+	return settings
+}
+
+// mergeVariables replaces parent variables with child variables of the same
+// name and appends the rest.
+func mergeVariables(parent interface{}, child interface{}) interface{} {
+	parentList, _ := parent.([]interface{})
+	childList, _ := child.([]interface{})
+	merged := append([]interface{}{}, parentList...)
+
+	for i := range childList {
+		replaced := false
+		childVar, _ := childList[i].(Values)
+
+		for k := range merged {
+			parentVar, _ := merged[k].(Values)
+
+			if childVar != nil && parentVar != nil && strings.EqualFold(fmt.Sprint(parentVar["name"]), fmt.Sprint(childVar["name"])) {
+				merged[k] = childVar
+				replaced = true
+				break
+			}
+		}
+
+		if !replaced {
+			merged = append(merged, childList[i])
+		}
+	}
+
+	return merged
+}
+
+// mergeLists merges maps whose list values are concatenated and whose other
+// values are replaced.
+func mergeLists(parent interface{}, child interface{}) interface{} {
+	parentMap, parentIsMap := parent.(Values)
+	childMap, childIsMap := child.(Values)
+
+	if !parentIsMap || !childIsMap {
+		return child
+	}
+
+	merged := Values{}.Merge(parentMap)
+
+	for key, value := range childMap {
+		if _, isList := value.([]interface{}); isList {
+			merged[key] = concatLists(merged[key], value)
+		} else {
+			merged[key] = value
+		}
+	}
+
+	return merged
+}
+
+func concatLists(parent interface{}, child interface{}) interface{} {
+	parentList, _ := parent.([]interface{})
+	childList, _ := child.([]interface{})
+
+	return append(append([]interface{}{}, parentList...), childList...)
+}
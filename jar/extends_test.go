@@ -0,0 +1,198 @@
+// Copyright © 2018 Steve Huff <steve.huff@asics.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package jar
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestResolveParents(t *testing.T) {
+	tests := []struct {
+		name    string
+		jars    map[string]string
+		valid   []string
+		invalid map[string]string
+	}{
+		{
+			name: "chains",
+			jars: map[string]string{
+				"base":      "prefix: base-",
+				"service":   "extends: base",
+				"lambda":    "extends: service",
+				"unrelated": "prefix: x-",
+			},
+			valid: []string{"base", "lambda", "service", "unrelated"},
+		},
+		{
+			name: "missing parents",
+			jars: map[string]string{
+				"orphan":     "extends: missing",
+				"grandchild": "extends: orphan",
+			},
+			invalid: map[string]string{
+				"orphan":     "extends missing, which is not a valid jar",
+				"grandchild": "extends missing, which is not a valid jar",
+			},
+		},
+		{
+			name: "cycles",
+			jars: map[string]string{
+				"self":  "extends: self",
+				"a":     "extends: b",
+				"b":     "extends: a",
+				"child": "extends: a",
+				"base":  "prefix: base-",
+			},
+			valid: []string{"base"},
+			invalid: map[string]string{
+				"self":  "jar inheritance cycle: self -> self",
+				"a":     "jar inheritance cycle: a -> b -> a",
+				"b":     "jar inheritance cycle: b -> a -> b",
+				"child": "jar inheritance cycle: child -> a -> b -> a",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		var jars []Jar
+
+		for name, yaml := range test.jars {
+			jars = append(jars, testJar(t, name, yaml))
+		}
+
+		resolved, invalid := resolveParents(jars)
+
+		var valid []string
+
+		for _, j := range resolved {
+			valid = append(valid, j.Name())
+		}
+
+		if !reflect.DeepEqual(sortedStrings(valid), sortedStrings(test.valid)) {
+			t.Errorf("%v: got valid jars %v, want %v", test.name, valid, test.valid)
+		}
+
+		got := map[string]string{}
+
+		for _, i := range invalid {
+			got[i.Name] = i.Error
+		}
+
+		if len(got) != len(test.invalid) {
+			t.Errorf("%v: got invalid jars %v, want %v", test.name, got, test.invalid)
+		}
+
+		for name, want := range test.invalid {
+			if !strings.Contains(got[name], want) {
+				t.Errorf("%v: jar %v: got error %q, want %q", test.name, name, got[name], want)
+			}
+		}
+	}
+}
+
+func TestResolveParentsMergesMetadata(t *testing.T) {
+	base := testJar(t, "base", `
+prefix: base-
+values:
+  owner: platform
+  region: us
+variables:
+  - name: replicas
+    type: int
+    default: 2
+  - name: tier
+hooks:
+  post_open:
+    - command: [git, init]
+`)
+	child := testJar(t, "child", `
+extends: base
+prefix: child-
+values:
+  region: eu
+variables:
+  - name: replicas
+    type: int
+    default: 3
+  - name: memory
+    type: int
+hooks:
+  post_open:
+    - command: [make]
+`)
+
+	resolved, invalid := resolveParents([]Jar{child, base})
+
+	if len(resolved) != 2 || len(invalid) > 0 {
+		t.Fatalf("got %v resolved and %v invalid jars", len(resolved), invalid)
+	}
+
+	if layers := Layers(child); len(layers) != 2 || layers[0] != Jar(base) || layers[1] != Jar(child) {
+		t.Errorf("got layers %v", layers)
+	}
+
+	if prefix := child.Prefix(); prefix != "child-" {
+		t.Errorf("got prefix %v, want child-", prefix)
+	}
+
+	if want := (Values{"owner": "platform", "region": "eu"}); !reflect.DeepEqual(DefaultValues(child.Metadata()), want) {
+		t.Errorf("got values %v, want %v", DefaultValues(child.Metadata()), want)
+	}
+
+	variables, err := ParseVariables(child.Metadata())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+
+	for _, v := range variables {
+		names = append(names, v.Name)
+
+		if v.Name == "replicas" && v.Default != 3 {
+			t.Errorf("expected the child's replicas to replace the parent's, got default %v", v.Default)
+		}
+	}
+
+	if want := []string{"replicas", "tier", "memory"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got variables %v, want %v", names, want)
+	}
+
+	hooks, _ := ParseHooks(child.Metadata(), HookStagePostOpen)
+
+	if len(hooks) != 2 || hooks[0].Name != "git init" || hooks[1].Name != "make" {
+		t.Errorf("expected the parent's hooks to run first, got %+v", hooks)
+	}
+
+	if child.OwnMetadata().IsSet("hooks.pre_open") || base.Prefix() != "base-" {
+		t.Error("expected the parent's own metadata to be unchanged")
+	}
+}
+
+func sortedStrings(s []string) []string {
+	sorted := append([]string{}, s...)
+	sort.Strings(sorted)
+	return sorted
+}
@@ -0,0 +1,420 @@
+// Copyright © 2018 Steve Huff <steve.huff@asics.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package jar
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	jww "github.com/spf13/jwalterweatherman"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+var hashPattern = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// ResolveRef finds the commit named by a branch of remote, a tag or a commit
+// SHA.  If ref is a branch, the name of the local branch is also returned.
+func ResolveRef(r *git.Repository, remote string, ref string) (plumbing.Hash, plumbing.ReferenceName, error) {
+	if branch, err := r.Reference(plumbing.NewRemoteReferenceName(remote, ref), true); err == nil {
+		return branch.Hash(), plumbing.NewBranchReferenceName(ref), nil
+	}
+
+	if tag, err := r.Reference(plumbing.NewTagReferenceName(ref), true); err == nil {
+		hash, err := PeelTag(r, tag.Hash())
+		return hash, "", err
+	}
+
+	if hashPattern.MatchString(ref) {
+		if commit, err := r.CommitObject(plumbing.NewHash(ref)); err == nil {
+			return commit.Hash, "", nil
+		}
+	}
+
+	hash, err := r.ResolveRevision(plumbing.Revision(ref))
+
+	if err != nil {
+		return plumbing.ZeroHash, "", fmt.Errorf("unable to find a branch, tag or commit named %v", ref)
+	}
+
+	return *hash, "", nil
+}
+
+// PeelTag returns the commit an annotated tag points at.  Any other hash is
+// returned unchanged.
+func PeelTag(r *git.Repository, hash plumbing.Hash) (plumbing.Hash, error) {
+	tag, err := r.TagObject(hash)
+
+	if err == plumbing.ErrObjectNotFound {
+		return hash, nil
+	}
+
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	commit, err := tag.Commit()
+
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return commit.Hash, nil
+}
+
+// IsAncestor reports whether the commit ancestor is reachable from the commit
+// descendant, so that moving a branch from one to the other is a fast-forward.
+func IsAncestor(r *git.Repository, ancestor plumbing.Hash, descendant plumbing.Hash) (bool, error) {
+	found := false
+
+	err := walkCommits(r, descendant, func(commit *object.Commit) bool {
+		found = found || commit.Hash == ancestor
+		return !found
+	})
+
+	return found, err
+}
+
+// CommitsBetween returns the commits which are reachable from to but not from
+// from, newest first.  from may be the zero hash.
+func CommitsBetween(r *git.Repository, from plumbing.Hash, to plumbing.Hash) ([]*object.Commit, error) {
+	seen := map[plumbing.Hash]bool{}
+
+	if !from.IsZero() {
+		err := walkCommits(r, from, func(commit *object.Commit) bool {
+			seen[commit.Hash] = true
+			return true
+		})
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var commits []*object.Commit
+
+	err := walkCommits(r, to, func(commit *object.Commit) bool {
+		if seen[commit.Hash] {
+			return false
+		}
+
+		commits = append(commits, commit)
+		return true
+	})
+
+	sort.SliceStable(commits, func(i, j int) bool {
+		return commits[i].Committer.When.After(commits[j].Committer.When)
+	})
+
+	return commits, err
+}
+
+// walkCommits visits start and its ancestors, each once, descending into the
+// parents of a commit only if visit returns true.
+func walkCommits(r *git.Repository, start plumbing.Hash, visit func(*object.Commit) bool) error {
+	seen := map[plumbing.Hash]bool{}
+	pending := []plumbing.Hash{start}
+
+	for len(pending) > 0 {
+		hash := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		if seen[hash] {
+			continue
+		}
+
+		seen[hash] = true
+		commit, err := r.CommitObject(hash)
+
+		if err != nil {
+			return err
+		}
+
+		if visit(commit) {
+			pending = append(pending, commit.ParentHashes...)
+		}
+	}
+
+	return nil
+}
+
+// LastModified finds the most recent commit which changed each of the named
+// jars, following the first parent of each commit back from HEAD.  The
+// commits found are kept in the repository's index, so that the history is
+// only searched once for each commit HEAD points at.
+func LastModified(repo *Repository, names []string) (map[string]CommitSummary, error) {
+	r, err := git.PlainOpen(repo.Dir)
+
+	if err != nil {
+		return nil, err
+	}
+
+	head, err := r.Head()
+
+	if err != nil {
+		return nil, err
+	}
+
+	filename := indexFile(repo)
+	index, err := loadIndex(filename)
+
+	if err != nil {
+		jww.DEBUG.Printf("ignoring index of %v: %v", repo.Label(), err)
+	}
+
+	if index == nil || index.Version != indexVersion || index.Commit != head.Hash().String() {
+		index = nil
+	}
+
+	modified := map[string]CommitSummary{}
+	var missing []string
+
+	for _, name := range names {
+		if commit, ok := index.modified(name); ok {
+			modified[name] = commit
+		} else {
+			missing = append(missing, name)
+		}
+	}
+
+	if len(missing) == 0 {
+		return modified, nil
+	}
+
+	found, err := lastModified(r, head.Hash(), missing)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for name, commit := range found {
+		modified[name] = commit
+	}
+
+	if index != nil {
+		if index.Modified == nil {
+			index.Modified = map[string]CommitSummary{}
+		}
+
+		for name, commit := range found {
+			index.Modified[name] = commit
+		}
+
+		if err := index.save(filename); err != nil {
+			jww.WARN.Printf("unable to save the index of %v: %v", repo.Label(), err)
+		}
+	}
+
+	return modified, nil
+}
+
+// lastModified searches the history from hash for the most recent commit
+// which changed each of the named jars.
+func lastModified(r *git.Repository, hash plumbing.Hash, names []string) (map[string]CommitSummary, error) {
+	commit, err := r.CommitObject(hash)
+
+	if err != nil {
+		return nil, err
+	}
+
+	pending := map[string]bool{}
+
+	for _, name := range names {
+		pending[name] = true
+	}
+
+	modified := map[string]CommitSummary{}
+
+	for commit != nil && len(pending) > 0 {
+		tree, err := commit.Tree()
+
+		if err != nil {
+			return nil, err
+		}
+
+		var parent *object.Commit
+		var parentTree *object.Tree
+
+		if commit.NumParents() > 0 {
+			parent, err = commit.Parent(0)
+
+			if err != nil {
+				return nil, err
+			}
+
+			parentTree, err = parent.Tree()
+
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		for name := range pending {
+			if entryHash(tree, name) != entryHash(parentTree, name) {
+				modified[name] = summarizeCommit(commit)
+				delete(pending, name)
+			}
+		}
+
+		commit = parent
+	}
+
+	return modified, nil
+}
+
+// entryHash returns the hash of the file or directory at path in a tree, or
+// the zero hash if there is nothing there.
+func entryHash(tree *object.Tree, path string) plumbing.Hash {
+	if tree == nil {
+		return plumbing.ZeroHash
+	}
+
+	entry, err := tree.FindEntry(path)
+
+	if err != nil {
+		return plumbing.ZeroHash
+	}
+
+	return entry.Hash
+}
+
+// DefaultBranch returns the name of the branch which was checked out when a
+// repository was cloned from remote.
+func DefaultBranch(r *git.Repository, remote string) (string, error) {
+	head, err := r.Reference(plumbing.ReferenceName(fmt.Sprintf("refs/remotes/%v/HEAD", remote)), false)
+
+	if err == nil && head.Type() == plumbing.SymbolicReference {
+		return strings.TrimPrefix(head.Target().String(), fmt.Sprintf("refs/remotes/%v/", remote)), nil
+	}
+
+	// clones made before the remote HEAD was recorded
+	for _, name := range []string{"master", "main"} {
+		if _, err := r.Reference(plumbing.NewBranchReferenceName(name), false); err == nil {
+			return name, nil
+		}
+	}
+
+	return "", fmt.Errorf("unable to determine the default branch of %v", remote)
+}
+
+// RecordDefaultBranch remembers the branch checked out by a fresh clone, as
+// git does, so that it can be found again once something else is checked
+// out.
+func RecordDefaultBranch(r *git.Repository, remote string) error {
+	head, err := r.Head()
+
+	if err != nil {
+		return err
+	}
+
+	if !head.Name().IsBranch() {
+		return nil
+	}
+
+	name := plumbing.ReferenceName(fmt.Sprintf("refs/remotes/%v/HEAD", remote))
+	target := plumbing.NewRemoteReferenceName(remote, head.Name().Short())
+
+	return r.Storer.SetReference(plumbing.NewSymbolicReference(name, target))
+}
+
+// ParseRevisionJars parses the jars in a repository as they were at ref,
+// reading them from the git object database.  The jars may extend the jars in
+// others.
+func ParseRevisionJars(repo *Repository, ref string, others []Jar) ([]Jar, error) {
+	r, err := git.PlainOpen(repo.Dir)
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to open %v: %v", repo.Dir, err)
+	}
+
+	hash, _, err := ResolveRef(r, repo.Remote, ref)
+
+	if err != nil {
+		return nil, err
+	}
+
+	jars, _, err := commitJars(r, repo, hash)
+
+	if err != nil {
+		return nil, err
+	}
+
+	resolved, _ := resolveParentsWith(jars, others)
+	return resolved, nil
+}
+
+// headJars parses the jars at the commit a repository's HEAD points at,
+// using the repository's index if it is up to date.
+func headJars(repo *Repository) ([]Jar, []InvalidJar, error) {
+	r, err := git.PlainOpen(repo.Dir)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	head, err := r.Head()
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sources, modTime, err := indexedSources(r, repo, head.Hash())
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	jars, invalid := parseJarSources(repo, head.Hash(), modTime, sources)
+	return jars, invalid, nil
+}
+
+// commitJars parses the jars in the tree of a commit.  Each jar is read
+// through a TreeFs, so it is unaffected by anything else using the
+// repository.  Directories with metadata which can't be parsed are returned
+// as invalid jars.
+func commitJars(r *git.Repository, repo *Repository, hash plumbing.Hash) ([]Jar, []InvalidJar, error) {
+	commit, err := r.CommitObject(hash)
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to read commit %v: %v", hash, err)
+	}
+
+	tree, err := commit.Tree()
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to read commit %v: %v", hash, err)
+	}
+
+	jww.DEBUG.Printf("parsing jars from %v at %v", repo.Dir, hash)
+	sources, err := findJarSources(tree, "")
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to read commit %v: %v", hash, err)
+	}
+
+	parseSourceMetadata(sources)
+
+	jars, invalid := parseJarSources(repo, hash, commit.Committer.When, sources)
+	return jars, invalid, nil
+}
@@ -0,0 +1,263 @@
+// Copyright © 2018 Steve Huff <steve.huff@asics.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package jar
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	jww "github.com/spf13/jwalterweatherman"
+	"github.com/spf13/viper"
+)
+
+const (
+	HookStagePreOpen  = "pre_open"
+	HookStagePostOpen = "post_open"
+)
+
+// Hook is a command declared under "hooks.<stage>" in a jar's metadata.
+// Arguments and the working directory may contain template expressions.
+type Hook struct {
+	Name    string   `mapstructure:"name" json:"name,omitempty" yaml:"name,omitempty"`
+	Command []string `mapstructure:"command" json:"command" yaml:"command"`
+	Dir     string   `mapstructure:"dir" json:"dir,omitempty" yaml:"dir,omitempty"`
+}
+
+// ParseHooks decodes and checks the hooks for a stage in a jar's metadata.
+func ParseHooks(metadata *viper.Viper, stage string) ([]Hook, error) {
+	var hooks []Hook
+	key := fmt.Sprintf("hooks.%v", stage)
+
+	if metadata == nil || !metadata.IsSet(key) {
+		return hooks, nil
+	}
+
+	err := metadata.UnmarshalKey(key, &hooks)
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse %v hooks: %v", stage, err)
+	}
+
+	var problems []string
+
+	for i := range hooks {
+		h := &hooks[i]
+
+		// a command given as a single string is split on whitespace, which
+		// would also split any template actions in it
+		if len(h.Command) == 1 {
+			if strings.Contains(h.Command[0], "{{") {
+				problems = append(problems, fmt.Sprintf("%v hook #%v uses a template in a command given as a string; give the command as a list", stage, i+1))
+				continue
+			}
+
+			h.Command = strings.Fields(h.Command[0])
+		}
+
+		if len(h.Command) == 0 {
+			problems = append(problems, fmt.Sprintf("%v hook #%v has no command", stage, i+1))
+			continue
+		}
+
+		if len(h.Name) == 0 {
+			h.Name = strings.Join(h.Command, " ")
+		}
+	}
+
+	if len(problems) > 0 {
+		return hooks, fmt.Errorf("invalid hooks:\n  - %v", strings.Join(problems, "\n  - "))
+	}
+
+	return hooks, nil
+}
+
+// Render returns the hook's arguments and working directory with their
+// template expressions rendered.  Relative directories are relative to
+// baseDir, which is also the default.
+func (h Hook) Render(data TemplateData, baseDir string) ([]string, string, error) {
+	args := make([]string, len(h.Command))
+
+	for i := range h.Command {
+		arg, err := renderString(h.Command[i], data)
+
+		if err != nil {
+			return nil, "", err
+		}
+
+		args[i] = arg
+	}
+
+	dir, err := renderString(h.Dir, data)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(baseDir, dir)
+	}
+
+	return args, dir, nil
+}
+
+// Run executes the hook, streaming its output to the terminal and the log.
+func (h Hook) Run(data TemplateData, baseDir string) error {
+	args, dir, err := h.Render(data, baseDir)
+
+	if err != nil {
+		return fmt.Errorf("hook '%v': %v", h.Name, err)
+	}
+
+	jww.INFO.Printf("running hook '%v' in %v: %v", h.Name, dir, args)
+
+	stdout := &lineWriter{print: hookOutput(h.Name, os.Stdout)}
+	stderr := &lineWriter{print: hookOutput(h.Name, os.Stderr)}
+
+	c := exec.Command(args[0], args[1:]...)
+	c.Dir = dir
+	c.Stdin = os.Stdin
+	c.Stdout = stdout
+	c.Stderr = stderr
+	c.Env = append(os.Environ(),
+		fmt.Sprintf("MASONJAR_JAR=%v", data["Jar"]),
+		fmt.Sprintf("MASONJAR_IDENTIFIER=%v", data["Identifier"]),
+		fmt.Sprintf("MASONJAR_DEST_ROOT=%v", data["DestRoot"]),
+	)
+
+	err = c.Run()
+	stdout.Flush()
+	stderr.Flush()
+
+	if err != nil {
+		return fmt.Errorf("hook '%v' failed: %v", h.Name, err)
+	}
+
+	return nil
+}
+
+// RunHooks runs a jar's hooks for a stage in the order they were declared,
+// stopping at the first failure.  Hooks run in baseDir by default.
+func RunHooks(j Jar, stage string, data TemplateData, baseDir string) error {
+	return RunLayerHooks([]Jar{j}, stage, data, baseDir)
+}
+
+// RunLayerHooks runs the hooks of every layer of jars for a stage, starting
+// with the root of each inheritance chain.  A jar extended by more than one
+// of the jars only runs its hooks once.
+func RunLayerHooks(jars []Jar, stage string, data TemplateData, baseDir string) error {
+	ran := map[string]bool{}
+
+	for _, top := range jars {
+		layers := Layers(top)
+
+		for i := range layers {
+			if ran[layers[i].Path()] {
+				jww.DEBUG.Printf("%v hooks for jar %v have already run", stage, QualifiedName(layers[i]))
+				continue
+			}
+
+			ran[layers[i].Path()] = true
+
+			hooks, err := ParseHooks(layers[i].OwnMetadata(), stage)
+
+			if err != nil {
+				return fmt.Errorf("jar %v: %v", layers[i].Name(), err)
+			}
+
+			for h := range hooks {
+				err = hooks[h].Run(data, baseDir)
+
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// hookOutput returns a function which logs a line of a hook's output and
+// echoes it to the terminal, unless the log is already being echoed there.
+func hookOutput(name string, terminal io.Writer) func(string) {
+	return func(line string) {
+		jww.INFO.Printf("[%v] %v", name, line)
+
+		if jww.GetStdoutThreshold() > jww.LevelInfo {
+			fmt.Fprintln(terminal, line)
+		}
+	}
+}
+
+func renderString(text string, data TemplateData) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+
+	tmpl, err := template.New(text).Option("missingkey=error").Parse(text)
+
+	if err != nil {
+		return "", err
+	}
+
+	var rendered bytes.Buffer
+	err = tmpl.Execute(&rendered, data)
+
+	return rendered.String(), err
+}
+
+// lineWriter passes each complete line written to it to print.
+type lineWriter struct {
+	buf   bytes.Buffer
+	print func(string)
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+
+	for {
+		line, err := w.buf.ReadString('\n')
+
+		if err != nil {
+			// incomplete line, keep it for the next write
+			w.buf.WriteString(line)
+			break
+		}
+
+		w.print(strings.TrimRight(line, "\r\n"))
+	}
+
+	return len(p), nil
+}
+
+// Flush prints any incomplete line left in the buffer.
+func (w *lineWriter) Flush() {
+	if w.buf.Len() > 0 {
+		w.print(w.buf.String())
+		w.buf.Reset()
+	}
+}
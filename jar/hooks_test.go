@@ -0,0 +1,144 @@
+// Copyright © 2018 Steve Huff <steve.huff@asics.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package jar
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseHooks(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		want    [][]string
+		wantErr string
+	}{
+		{
+			name: "a string is split on whitespace",
+			yaml: "hooks:\n  post_open:\n    - command: \"git  init  .\"\n",
+			want: [][]string{{"git", "init", "."}},
+		},
+		{
+			name: "a list is used as is",
+			yaml: "hooks:\n  post_open:\n    - command: [echo, \"{{ printf \\\"%s-%s\\\" .a .b }}\"]\n",
+			want: [][]string{{"echo", `{{ printf "%s-%s" .a .b }}`}},
+		},
+		{
+			name:    "a string may not contain templates",
+			yaml:    "hooks:\n  post_open:\n    - command: \"go mod init github.com/x/{{ .Identifier }}\"\n",
+			wantErr: "post_open hook #1 uses a template in a command given as a string",
+		},
+		{
+			name:    "a command is required",
+			yaml:    "hooks:\n  post_open:\n    - name: nothing\n",
+			wantErr: "post_open hook #1 has no command",
+		},
+	}
+
+	for _, test := range tests {
+		hooks, err := ParseHooks(testMetadata(t, test.yaml), HookStagePostOpen)
+
+		if len(test.wantErr) > 0 {
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("%v: got error %v, want %q", test.name, err, test.wantErr)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", test.name, err)
+			continue
+		}
+
+		var got [][]string
+
+		for _, h := range hooks {
+			got = append(got, h.Command)
+		}
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestHookRender(t *testing.T) {
+	h := Hook{Command: []string{"echo", `{{ printf "%s-%s" .a .b }}`, "{{ .a }} {{ .b }}"}, Dir: "{{ .a }}"}
+	args, dir, err := h.Render(TemplateData{"a": "x", "b": "y z"}, "/base")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"echo", "x-y z", "x y z"}; !reflect.DeepEqual(args, want) {
+		t.Errorf("got %q, want %q", args, want)
+	}
+
+	if dir != "/base/x" {
+		t.Errorf("got dir %v, want /base/x", dir)
+	}
+}
+
+func TestRunLayerHooksRunsSharedLayersOnce(t *testing.T) {
+	dir, err := ioutil.TempDir("", "masonjar-hooks")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	hook := func(name string) string {
+		return "hooks:\n  post_open:\n    - command: [sh, -c, \"echo " + name + " >> ran\"]\n"
+	}
+
+	base := testJar(t, "base", hook("base"))
+	web := testJar(t, "web", "extends: base\n"+hook("web"))
+	worker := testJar(t, "worker", "extends: base\n"+hook("worker"))
+
+	resolved, invalid := resolveParents([]Jar{base, web, worker})
+
+	if len(resolved) != 3 || len(invalid) > 0 {
+		t.Fatalf("got %v resolved and %v invalid jars", len(resolved), invalid)
+	}
+
+	err = RunLayerHooks([]Jar{base, web, worker}, HookStagePostOpen, TemplateData{}, dir)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ran, err := ioutil.ReadFile(filepath.Join(dir, "ran"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "base\nweb\nworker\n"; string(ran) != want {
+		t.Errorf("got hooks %q, want %q", ran, want)
+	}
+}
@@ -0,0 +1,402 @@
+// Copyright © 2018 Steve Huff <steve.huff@asics.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package jar
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/afero"
+	jww "github.com/spf13/jwalterweatherman"
+	"github.com/spf13/viper"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+// indexVersion changes whenever the format of the index changes, so that
+// indexes written by other versions of masonjar are rebuilt.
+const indexVersion = 2
+
+// catalogIndex records where the jars are in a commit of a repository, along
+// with their parsed metadata and files, so that they can be found again without
+// searching the commit's tree.  The last commit which changed each jar is
+// added once it has been looked up.
+type catalogIndex struct {
+	Version  int                      `json:"version"`
+	Commit   string                   `json:"commit"`
+	Time     time.Time                `json:"time"`
+	Jars     []jarSource              `json:"jars"`
+	Modified map[string]CommitSummary `json:"modified,omitempty"`
+}
+
+// modified returns the last commit which changed the named jar, if it has
+// been recorded.
+func (index *catalogIndex) modified(name string) (CommitSummary, bool) {
+	if index == nil {
+		return CommitSummary{}, false
+	}
+
+	commit, ok := index.Modified[name]
+	return commit, ok
+}
+
+// jarSource is a directory in a commit which holds a jar, along with its
+// metadata settings, or why its metadata can't be parsed.
+type jarSource struct {
+	Name     string          `json:"name"`
+	Tree     string          `json:"tree"`
+	Settings json.RawMessage `json:"settings,omitempty"`
+	Error    string          `json:"error,omitempty"`
+	Files    []string        `json:"files"`
+
+	tree   *object.Tree
+	data   []byte
+	format string
+}
+
+// IndexRepository rebuilds the index of the jars at a repository's HEAD.
+func IndexRepository(repo *Repository) error {
+	r, err := git.PlainOpen(repo.Dir)
+
+	if err != nil {
+		return err
+	}
+
+	head, err := r.Head()
+
+	if err != nil {
+		return err
+	}
+
+	_, _, err = indexCommit(r, repo, head.Hash())
+	return err
+}
+
+// indexedSources returns the jars in a commit from the repository's index,
+// rebuilding the index if it was made for a different commit.
+func indexedSources(r *git.Repository, repo *Repository, hash plumbing.Hash) ([]jarSource, time.Time, error) {
+	index, err := loadIndex(indexFile(repo))
+
+	if err != nil {
+		jww.DEBUG.Printf("ignoring index of %v: %v", repo.Label(), err)
+	}
+
+	if index == nil || index.Version != indexVersion || index.Commit != hash.String() {
+		return indexCommit(r, repo, hash)
+	}
+
+	jww.DEBUG.Printf("using index of %v at %v", repo.Label(), hash)
+
+	for i := range index.Jars {
+		index.Jars[i].tree, err = r.TreeObject(plumbing.NewHash(index.Jars[i].Tree))
+
+		if err != nil {
+			jww.DEBUG.Printf("ignoring index of %v: %v", repo.Label(), err)
+			return indexCommit(r, repo, hash)
+		}
+	}
+
+	return index.Jars, index.Time, nil
+}
+
+// indexCommit finds the jars in a commit and saves them as the repository's
+// index.  Failing to save the index is not an error, since it only makes
+// later commands slower.
+func indexCommit(r *git.Repository, repo *Repository, hash plumbing.Hash) ([]jarSource, time.Time, error) {
+	commit, err := r.CommitObject(hash)
+
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("unable to read commit %v: %v", hash, err)
+	}
+
+	tree, err := commit.Tree()
+
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("unable to read commit %v: %v", hash, err)
+	}
+
+	jww.INFO.Printf("indexing jars in %v at %v", repo.Label(), hash)
+	sources, err := findJarSources(tree, "")
+
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("unable to read commit %v: %v", hash, err)
+	}
+
+	parseSourceMetadata(sources)
+
+	index := &catalogIndex{
+		Version: indexVersion,
+		Commit:  hash.String(),
+		Time:    commit.Committer.When,
+		Jars:    sources,
+	}
+
+	if filename := indexFile(repo); len(filename) > 0 {
+		if err := index.save(filename); err != nil {
+			jww.WARN.Printf("unable to save the index of %v: %v", repo.Label(), err)
+		}
+	}
+
+	return sources, index.Time, nil
+}
+
+// indexFile returns the name of the file in which a repository's index is
+// kept, or nothing if indexes are not kept.
+func indexFile(repo *Repository) string {
+	dir := viper.GetString("IndexDir")
+
+	if len(dir) == 0 || len(repo.URL) == 0 {
+		return ""
+	}
+
+	return filepath.Join(dir, repo.CacheKey()+".json")
+}
+
+func loadIndex(filename string) (*catalogIndex, error) {
+	if len(filename) == 0 {
+		return nil, nil
+	}
+
+	data, err := afero.ReadFile(afero.NewOsFs(), filename)
+
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	index := new(catalogIndex)
+	err = json.Unmarshal(data, index)
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse %v: %v", filename, err)
+	}
+
+	return index, nil
+}
+
+// save writes the index to a temporary file which then replaces filename, so
+// that a command reading the index never sees half of it.
+func (index *catalogIndex) save(filename string) error {
+	data, err := json.Marshal(index)
+
+	if err != nil {
+		return err
+	}
+
+	fs := afero.NewOsFs()
+	err = fs.MkdirAll(filepath.Dir(filename), 0700)
+
+	if err != nil {
+		return err
+	}
+
+	f, err := afero.TempFile(fs, filepath.Dir(filename), ".index-")
+
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(data)
+
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = fs.Rename(f.Name(), filename)
+	}
+
+	if err != nil {
+		fs.Remove(f.Name())
+	}
+
+	return err
+}
+
+// findJarSources searches a tree for jars.  Any directory containing a
+// metadata file is a jar, named by its path, e.g. "aws/lambda-go".
+// Directories inside a jar, and hidden directories, are not searched for more
+// jars.
+func findJarSources(tree *object.Tree, dir string) ([]jarSource, error) {
+	var sources []jarSource
+
+	for _, entry := range tree.Entries {
+		if entry.Mode != filemode.Dir || strings.HasPrefix(entry.Name, ".") {
+			continue
+		}
+
+		subtree, err := tree.Tree(entry.Name)
+
+		if err != nil {
+			return nil, err
+		}
+
+		name := path.Join(dir, entry.Name)
+		source, err := readJarSource(name, subtree)
+
+		if _, ok := err.(*missingMetadataError); ok {
+			jww.DEBUG.Printf("searching %v for jars", name)
+			found, err := findJarSources(subtree, name)
+
+			if err != nil {
+				return nil, err
+			}
+
+			sources = append(sources, found...)
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		sources = append(sources, *source)
+	}
+
+	return sources, nil
+}
+
+// readJarSource reads the metadata and lists the files of the jar in tree.
+func readJarSource(name string, tree *object.Tree) (*jarSource, error) {
+	data, ext, err := readMetadata(NewTreeFs(tree, time.Time{}), name, MetadataFileName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	source := &jarSource{Name: name, Tree: tree.Hash.String(), tree: tree, data: data, format: ext}
+
+	err = tree.Files().ForEach(func(f *object.File) error {
+		source.Files = append(source.Files, f.Name)
+		return nil
+	})
+
+	sort.Strings(source.Files)
+	return source, err
+}
+
+// parseSourceMetadata parses the metadata of each jar found in a commit into
+// the settings kept in the index.  The jars are parsed concurrently.
+func parseSourceMetadata(sources []jarSource) {
+	pending := make(chan int)
+	var wg sync.WaitGroup
+
+	for w := 0; w < runtime.NumCPU(); w++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := range pending {
+				sources[i].Settings, sources[i].Error = encodeSettings(sources[i].data, sources[i].format)
+			}
+		}()
+	}
+
+	for i := range sources {
+		pending <- i
+	}
+
+	close(pending)
+	wg.Wait()
+}
+
+// encodeSettings parses metadata and encodes its settings as JSON.
+func encodeSettings(data []byte, format string) (json.RawMessage, string) {
+	metadata, err := parseMetadata(data, format)
+
+	if err == nil {
+		var settings []byte
+		settings, err = json.Marshal(metadataSettings(metadata).toMap())
+
+		if err == nil {
+			return settings, ""
+		}
+	}
+
+	return nil, fmt.Sprintf("invalid metadata: %v", err)
+}
+
+// parseJarSources makes jars from the jars found in a commit, in the order
+// they were found.  Jars whose metadata couldn't be parsed are returned as
+// invalid jars.
+func parseJarSources(repo *Repository, hash plumbing.Hash, modTime time.Time, sources []jarSource) ([]Jar, []InvalidJar) {
+	var jars []Jar
+	var invalid []InvalidJar
+
+	for _, source := range sources {
+		metadata, err := decodeSettings(source)
+
+		if err != nil {
+			jww.WARN.Printf("%v at %v is not a valid jar: %v", source.Name, hash, err)
+			invalid = append(invalid, InvalidJar{Name: qualify(repo.Name, source.Name), Repository: repo.Label(), Error: err.Error()})
+			continue
+		}
+
+		j := &MasonJar{
+			name:     source.Name,
+			path:     fmt.Sprintf("%v@%v/%v", repo.Dir, hash, source.Name),
+			metadata: metadata,
+			own:      metadata,
+			repo:     repo,
+			fs:       NewTreeFs(source.tree, modTime),
+			files:    source.Files,
+		}
+
+		jww.INFO.Printf("parsed %v as jar %v", j.Path(), QualifiedName(j))
+		jars = append(jars, j)
+	}
+
+	return jars, invalid
+}
+
+// decodeSettings returns the metadata of a jar from the settings encoded by
+// encodeSettings.  Viper can only be given settings through a config reader,
+// but decoding JSON is much cheaper than parsing the original metadata.
+func decodeSettings(source jarSource) (*viper.Viper, error) {
+	if len(source.Error) > 0 {
+		return nil, fmt.Errorf("%v", source.Error)
+	}
+
+	metadata := viper.New()
+	metadata.SetConfigType("json")
+	err := metadata.ReadConfig(bytes.NewReader(source.Settings))
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid metadata: %v", err)
+	}
+
+	return metadata, nil
+}
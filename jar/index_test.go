@@ -0,0 +1,191 @@
+// Copyright © 2018 Steve Huff <steve.huff@asics.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package jar
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"gopkg.in/src-d/go-git.v4"
+)
+
+// testIndexedRepo creates a repository whose index is kept in a temporary
+// directory.  The returned function removes both.
+func testIndexedRepo(t *testing.T) (*git.Repository, *Repository, func()) {
+	r, dir := testGitRepo(t)
+	indexDir, err := ioutil.TempDir("", "masonjar-index")
+
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+
+	viper.Set("IndexDir", indexDir)
+
+	return r, &Repository{Name: "platform", URL: "https://example.com/platform.git", Dir: dir}, func() {
+		viper.Reset()
+		os.RemoveAll(dir)
+		os.RemoveAll(indexDir)
+	}
+}
+
+// rewriteIndex loads a repository's index, changes it and saves it again.
+func rewriteIndex(t *testing.T, repo *Repository, change func(index *catalogIndex)) {
+	index, err := loadIndex(indexFile(repo))
+
+	if err != nil || index == nil {
+		t.Fatalf("unable to load the index: %v", err)
+	}
+
+	change(index)
+
+	if err = index.save(indexFile(repo)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// jarPrefixes returns the prefixes of the jars at a repository's HEAD, keyed
+// by jar name, and the names of the invalid jars.
+func jarPrefixes(t *testing.T, repo *Repository) (map[string]string, []string) {
+	jars, invalid, err := headJars(repo)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prefixes := map[string]string{}
+
+	for _, j := range jars {
+		prefixes[j.Name()] = j.Prefix()
+	}
+
+	var names []string
+
+	for _, j := range invalid {
+		names = append(names, j.Name)
+	}
+
+	return prefixes, names
+}
+
+func TestIndexedJars(t *testing.T) {
+	r, repo, cleanup := testIndexedRepo(t)
+	defer cleanup()
+
+	commitFiles(t, r, repo.Dir, "Add jars", map[string]string{
+		"base/metadata.yaml":   "prefix: base-\nvalues:\n  memory: 104857600\n",
+		"broken/metadata.yaml": "prefix: [",
+	})
+
+	if err := IndexRepository(repo); err != nil {
+		t.Fatal(err)
+	}
+
+	// a hit uses the settings in the index rather than parsing the metadata
+	rewriteIndex(t, repo, func(index *catalogIndex) {
+		for i := range index.Jars {
+			if index.Jars[i].Name == "base" {
+				index.Jars[i].Settings = json.RawMessage(`{"prefix": "cached-", "values": {"memory": 104857600}}`)
+			}
+		}
+	})
+
+	prefixes, invalid := jarPrefixes(t, repo)
+
+	if want := map[string]string{"base": "cached-"}; !reflect.DeepEqual(prefixes, want) {
+		t.Errorf("got prefixes %v from the index, want %v", prefixes, want)
+	}
+
+	if want := []string{"platform/broken"}; !reflect.DeepEqual(invalid, want) {
+		t.Errorf("got invalid jars %v from the index, want %v", invalid, want)
+	}
+
+	jars, _, _ := headJars(repo)
+
+	if memory, _ := DefaultValues(jars[0].Metadata()).Get("memory"); memory != 104857600 {
+		t.Errorf("got memory %#v, want a whole number", memory)
+	}
+
+	// an index made for an older commit is rebuilt
+	head := commitFiles(t, r, repo.Dir, "Add a jar", map[string]string{
+		"child/metadata.yaml": "extends: base\nprefix: child-\n",
+	})
+
+	prefixes, _ = jarPrefixes(t, repo)
+
+	if want := map[string]string{"base": "base-", "child": "child-"}; !reflect.DeepEqual(prefixes, want) {
+		t.Errorf("got prefixes %v after a new commit, want %v", prefixes, want)
+	}
+
+	if index, _ := loadIndex(indexFile(repo)); index == nil || index.Commit != head.String() {
+		t.Errorf("expected the index to be rebuilt for %v, got %+v", head, index)
+	}
+
+	// an index written by another version of masonjar is rebuilt
+	rewriteIndex(t, repo, func(index *catalogIndex) {
+		index.Version = indexVersion - 1
+
+		for i := range index.Jars {
+			index.Jars[i].Settings = json.RawMessage(`{"prefix": "old-"}`)
+		}
+	})
+
+	prefixes, _ = jarPrefixes(t, repo)
+
+	if want := map[string]string{"base": "base-", "child": "child-"}; !reflect.DeepEqual(prefixes, want) {
+		t.Errorf("got prefixes %v from an old index, want %v", prefixes, want)
+	}
+
+	if index, _ := loadIndex(indexFile(repo)); index == nil || index.Version != indexVersion {
+		t.Errorf("expected the index to be rebuilt at version %v, got %+v", indexVersion, index)
+	}
+}
+
+func TestEncodeSettings(t *testing.T) {
+	settings, reason := encodeSettings([]byte("prefix: x-\ntemplates:\n  main.go: {}\n"), "yaml")
+
+	if len(reason) > 0 {
+		t.Fatal(reason)
+	}
+
+	metadata, err := decodeSettings(jarSource{Settings: settings})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if metadata.GetString("prefix") != "x-" || !IsTemplate("/main.go", metadata) {
+		t.Errorf("got settings %s", settings)
+	}
+
+	if _, reason = encodeSettings([]byte("prefix: ["), "yaml"); !strings.HasPrefix(reason, "invalid metadata: ") {
+		t.Errorf("got %q, want the metadata to be invalid", reason)
+	}
+
+	if _, err = decodeSettings(jarSource{Error: "invalid metadata: oops"}); err == nil || err.Error() != "invalid metadata: oops" {
+		t.Errorf("got %v, want the recorded error", err)
+	}
+}
@@ -0,0 +1,675 @@
+// Copyright © 2018 Steve Huff <steve.huff@asics.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package jar
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/viper"
+)
+
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// The checks made by Lint.
+const (
+	LintMetadata  = "metadata"
+	LintTemplates = "templates"
+	LintHooks     = "hooks"
+	LintSymlinks  = "symlinks"
+	LintPaths     = "paths"
+)
+
+// Finding is a problem found by Lint.  Errors would stop the jar from being
+// opened, or make it behave differently from what its author intended;
+// warnings are probably mistakes.
+type Finding struct {
+	Jar      string `json:"jar" yaml:"jar"`
+	Path     string `json:"path,omitempty" yaml:"path,omitempty"`
+	Severity string `json:"severity" yaml:"severity"`
+	Check    string `json:"check" yaml:"check"`
+	Message  string `json:"message" yaml:"message"`
+}
+
+// metadataKinds lists the keys allowed at the top level of a jar's metadata
+// and the kinds of value each may have.
+var metadataKinds = map[string][]string{
+	"conditions":    {"list"},
+	"delete":        {"list"},
+	"deprecated":    {"bool", "string"},
+	"description":   {"string"},
+	"extends":       {"string"},
+	"hooks":         {"map"},
+	"maintainers":   {"list"},
+	"preconditions": {"map"},
+	"prefix":        {"string"},
+	"tags":          {"list"},
+	"templates":     {"map"},
+	"values":        {"map"},
+	"variables":     {"list"},
+}
+
+var preconditionKeys = []string{"executables", "env", "not_in_git_repo"}
+
+// templateFields are the fields NewTemplateData provides alongside values.
+var templateFields = []string{"Jar", "Identifier", "Destination", "DestRoot", "Prefix", "Metadata"}
+
+// Finding returns the finding Lint would report for a jar which could not be
+// parsed.
+func (i InvalidJar) Finding() Finding {
+	return Finding{Jar: i.Name, Severity: SeverityError, Check: LintMetadata, Message: i.Error}
+}
+
+// Lint checks a jar for mistakes which its authors can fix: metadata which
+// doesn't match what masonjar expects, templates which don't parse or which
+// use undeclared values, hooks which run commands that can't be found,
+// symlinks which point outside the jar, and paths which can't be rendered or
+// which collide.  Paths are rendered with the default of each variable, or
+// an example value if it has none.
+func Lint(j Jar) []Finding {
+	l := &linter{jar: j, name: QualifiedName(j)}
+
+	_, ext, err := readMetadata(j.Fs(), j.Path(), MetadataFileName)
+
+	if err != nil {
+		l.report(SeverityError, LintMetadata, "", err.Error())
+		return l.findings
+	}
+
+	l.metadataFile = MetadataFileName + "." + ext
+	l.refs = newReferences(j.Metadata())
+
+	files, err := layerFiles(j)
+
+	if err != nil {
+		l.report(SeverityError, LintPaths, "", "unable to read the jar's files: %v", err)
+		return l.findings
+	}
+
+	l.lintMetadata()
+	l.lintTemplates(files)
+	l.lintHooks(files)
+	l.lintSymlinks()
+	l.lintPaths(files)
+
+	return l.findings
+}
+
+type linter struct {
+	jar          Jar
+	name         string
+	metadataFile string
+	refs         references
+	findings     []Finding
+}
+
+func (l *linter) report(severity string, check string, path string, format string, args ...interface{}) {
+	l.findings = append(l.findings, Finding{
+		Jar:      l.name,
+		Path:     strings.TrimPrefix(path, "/"),
+		Severity: severity,
+		Check:    check,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// reportErr reports each of the problems listed by an error returned while
+// parsing metadata.
+func (l *linter) reportErr(check string, path string, err error) {
+	if err == nil {
+		return
+	}
+
+	problems := strings.Split(err.Error(), "\n  - ")
+
+	if len(problems) > 1 {
+		problems = problems[1:]
+	}
+
+	for _, problem := range problems {
+		l.report(SeverityError, check, path, "%v", problem)
+	}
+}
+
+// reportUndeclared reports the values used by a template which are neither
+// variables nor defaults.  Jars which declare variables reject any other
+// value, so they are errors; otherwise they must be given with --set.
+func (l *linter) reportUndeclared(check string, path string, what string, tmpl *template.Template) {
+	for _, name := range l.refs.undeclared(tmpl) {
+		if l.refs.strict {
+			l.report(SeverityError, check, path, "%v uses %v, which is not a declared variable", what, name)
+		} else {
+			l.report(SeverityWarning, check, path, "%v uses %v, which has no default and must be given with --set", what, name)
+		}
+	}
+}
+
+// lintMetadata checks the jar's own metadata, leaving what it inherits to
+// the jars it extends.
+func (l *linter) lintMetadata() {
+	own := l.jar.OwnMetadata()
+	settings := own.AllSettings()
+
+	var keys []string
+
+	for key := range settings {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		kinds, ok := metadataKinds[key]
+
+		if !ok {
+			l.report(SeverityWarning, LintMetadata, l.metadataFile, "unknown key %v", key)
+			continue
+		}
+
+		if kind := kindOf(settings[key]); !contains(kinds, kind) {
+			l.report(SeverityError, LintMetadata, l.metadataFile, "%v should be a %v, not a %v", key, strings.Join(kinds, " or a "), kind)
+		}
+	}
+
+	for stage := range own.GetStringMap("hooks") {
+		if stage != HookStagePreOpen && stage != HookStagePostOpen {
+			l.report(SeverityWarning, LintHooks, l.metadataFile, "unknown hook stage %v; use %v or %v", stage, HookStagePreOpen, HookStagePostOpen)
+		}
+	}
+
+	for key := range own.GetStringMap("preconditions") {
+		if !contains(preconditionKeys, key) {
+			l.report(SeverityWarning, LintMetadata, l.metadataFile, "unknown precondition %v", key)
+		}
+	}
+
+	_, err := ParseVariables(own)
+	l.reportErr(LintMetadata, l.metadataFile, err)
+
+	_, err = ParsePreconditions(own)
+	l.reportErr(LintMetadata, l.metadataFile, err)
+
+	conditions, err := ParseConditions(own)
+	l.reportErr(LintMetadata, l.metadataFile, err)
+
+	for i := range conditions {
+		if tmpl, err := conditions[i].template(); err == nil {
+			l.reportUndeclared(LintMetadata, l.metadataFile, fmt.Sprintf("condition #%v", i+1), tmpl)
+		}
+	}
+}
+
+// lintTemplates checks that every file listed under "templates" exists, and
+// that the jar's templates parse.
+func (l *linter) lintTemplates(files map[string]os.FileMode) {
+	var names []string
+
+	for name := range l.jar.OwnMetadata().GetStringMap("templates") {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		found := false
+
+		for file := range files {
+			found = found || strings.EqualFold(path.Base(file), name)
+		}
+
+		if !found {
+			l.report(SeverityError, LintTemplates, l.metadataFile, "templates lists %v, which is not a file in the jar", name)
+		}
+	}
+
+	own, err := l.jar.Files()
+
+	if err != nil {
+		l.report(SeverityError, LintTemplates, "", "unable to read the jar's files: %v", err)
+		return
+	}
+
+	for _, file := range own {
+		if IsSkippable(file) || !IsTemplate(file, l.jar.Metadata()) {
+			continue
+		}
+
+		text, err := afero.ReadFile(l.jar.Fs(), file)
+
+		if err != nil {
+			l.report(SeverityError, LintTemplates, file, "unable to read template: %v", err)
+			continue
+		}
+
+		tmpl, err := template.New(file).Option("missingkey=error").Parse(string(text))
+
+		if err != nil {
+			l.report(SeverityError, LintTemplates, file, "unable to parse template: %v", err)
+			continue
+		}
+
+		l.reportUndeclared(LintTemplates, file, "template", tmpl)
+	}
+}
+
+// lintHooks checks that the commands run by the jar's hooks can be found.
+// Commands in the jar must be executable; other commands should be on the
+// PATH, unless they are listed as preconditions.
+func (l *linter) lintHooks(files map[string]os.FileMode) {
+	preconditions, _ := ParsePreconditions(l.jar.Metadata())
+	var executables []string
+
+	for i := range preconditions.Executables {
+		executables = append(executables, preconditions.Executables[i].Name)
+	}
+
+	for _, stage := range []string{HookStagePreOpen, HookStagePostOpen} {
+		hooks, err := ParseHooks(l.jar.OwnMetadata(), stage)
+		l.reportErr(LintHooks, l.metadataFile, err)
+
+		for _, h := range hooks {
+			if len(h.Command) == 0 {
+				continue
+			}
+
+			what := fmt.Sprintf("%v hook '%v'", stage, h.Name)
+
+			for _, text := range append([]string{h.Dir}, h.Command...) {
+				if !strings.Contains(text, "{{") {
+					continue
+				}
+
+				tmpl, err := template.New(text).Option("missingkey=error").Parse(text)
+
+				if err != nil {
+					l.report(SeverityError, LintHooks, l.metadataFile, "%v: unable to parse '%v': %v", what, text, err)
+					continue
+				}
+
+				l.reportUndeclared(LintHooks, l.metadataFile, what, tmpl)
+			}
+
+			command := h.Command[0]
+
+			switch {
+			case strings.Contains(command, "{{"):
+				// the command is only known once the jar is opened
+			case filepath.IsAbs(command):
+				if _, err := os.Stat(command); err != nil {
+					l.report(SeverityWarning, LintHooks, l.metadataFile, "%v runs %v, which does not exist on this machine", what, command)
+				}
+			case strings.Contains(command, "/"):
+				if stage == HookStagePreOpen {
+					l.report(SeverityWarning, LintHooks, l.metadataFile, "%v runs %v before the jar's files are written", what, command)
+					continue
+				}
+
+				if strings.Contains(h.Dir, "{{") {
+					continue
+				}
+
+				mode, ok := files[path.Join(h.Dir, command)]
+
+				if !ok {
+					l.report(SeverityError, LintHooks, l.metadataFile, "%v runs %v, which is not a file in the jar", what, command)
+				} else if mode&0111 == 0 {
+					l.report(SeverityError, LintHooks, l.metadataFile, "%v runs %v, which is not executable", what, command)
+				}
+			case contains(executables, command):
+			default:
+				if _, err := exec.LookPath(command); err != nil {
+					l.report(SeverityWarning, LintHooks, l.metadataFile, "%v runs %v, which is not on the PATH; list it under preconditions.executables if it is required", what, command)
+				}
+			}
+		}
+	}
+}
+
+// lintSymlinks checks that no symlink in the jar points outside it.
+func (l *linter) lintSymlinks() {
+	err := l.jar.Walk(func(file string, info os.FileInfo, err error) error {
+		if err != nil || info.Mode()&os.ModeSymlink == 0 {
+			return err
+		}
+
+		target, err := readLink(l.jar.Fs(), file)
+
+		if err != nil {
+			l.report(SeverityError, LintSymlinks, file, "unable to read symlink: %v", err)
+			return nil
+		}
+
+		resolved := path.Join(path.Dir(strings.TrimPrefix(file, "/")), filepath.ToSlash(target))
+
+		if filepath.IsAbs(target) || resolved == ".." || strings.HasPrefix(resolved, "../") {
+			l.report(SeverityError, LintSymlinks, file, "symlink points outside the jar: %v", target)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		l.report(SeverityError, LintSymlinks, "", "unable to read the jar's files: %v", err)
+	}
+}
+
+// lintPaths renders every path the jar would write, including those of the
+// jars it extends, and reports paths which can't be rendered or collide.
+func (l *linter) lintPaths(files map[string]os.FileMode) {
+	data := exampleData(l.jar)
+
+	own, _ := l.jar.Files()
+
+	for file := range files {
+		for _, segment := range strings.Split(file, "/") {
+			if !strings.Contains(segment, "{{") {
+				continue
+			}
+
+			tmpl, err := template.New(file).Parse(segment)
+
+			if err != nil {
+				// reported when the path is rendered
+				continue
+			}
+
+			// let the path render, as it would with a value from --set
+			for _, name := range l.refs.undeclared(tmpl) {
+				Values(data).Set(name, name)
+				data["Values"].(Values).Set(name, name)
+			}
+
+			if contains(own, "/"+file) {
+				l.reportUndeclared(LintPaths, file, "path", tmpl)
+			}
+		}
+	}
+
+	_, err := planLayers(l.jar.Name(), []Jar{l.jar}, data, nil)
+
+	if verr, ok := err.(*ValidationError); ok {
+		for _, problem := range verr.Problems {
+			l.report(SeverityError, LintPaths, "", "%v", problem)
+		}
+	} else if err != nil {
+		l.report(SeverityError, LintPaths, "", "%v", err)
+	}
+}
+
+// layerFiles returns the mode of every file in a jar and the jars it
+// extends, by path relative to the jar.
+func layerFiles(j Jar) (map[string]os.FileMode, error) {
+	files := map[string]os.FileMode{}
+
+	for _, layer := range Layers(j) {
+		err := layer.Walk(func(file string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() {
+				files[strings.TrimPrefix(file, "/")] = info.Mode()
+			}
+
+			return err
+		})
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return files, nil
+}
+
+// exampleData returns the data a jar's templates would be rendered with if
+// it were opened with the defaults of its variables.
+func exampleData(j Jar) TemplateData {
+	metadata := j.Metadata()
+	values := DefaultValues(metadata)
+	variables, _ := ParseVariables(metadata)
+
+	for _, v := range variables {
+		if _, ok := values.Get(v.Name); ok || len(v.Name) == 0 {
+			continue
+		}
+
+		if v.Default != nil {
+			values.Set(v.Name, normalizeValue(v.Default))
+		} else {
+			values.Set(v.Name, exampleValue(v))
+		}
+	}
+
+	data := NewTemplateData(metadata, values)
+	data["Jar"] = j.Name()
+	data["Identifier"] = "example"
+	data["Destination"] = "."
+	data["DestRoot"] = metadata.GetString("prefix") + "example"
+
+	return data
+}
+
+// exampleValue returns a valid value for a variable with no default, which
+// is distinct from other variables' values where possible.
+func exampleValue(v Variable) interface{} {
+	switch v.Type {
+	case VariableTypeInt:
+		if v.Min != nil {
+			return *v.Min
+		}
+
+		return 1
+	case VariableTypeBool:
+		return true
+	case VariableTypeChoice:
+		if len(v.Choices) > 0 {
+			return fmt.Sprint(v.Choices[0])
+		}
+	case VariableTypeList:
+		return []interface{}{v.Name}
+	}
+
+	return v.Name
+}
+
+// readLink returns the target of a symlink in a jar.
+func readLink(fs afero.Fs, name string) (string, error) {
+	switch typed := fs.(type) {
+	case *TreeFs:
+		// git stores the target as the content of the link
+		target, err := afero.ReadFile(typed, name)
+		return string(target), err
+	case *afero.BasePathFs:
+		realPath, err := typed.RealPath(name)
+
+		if err != nil {
+			return "", err
+		}
+
+		return os.Readlink(realPath)
+	default:
+		return "", fmt.Errorf("unable to read symlinks from %v", fs.Name())
+	}
+}
+
+func kindOf(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case []interface{}, []string:
+		return "list"
+	case map[string]interface{}, map[interface{}]interface{}:
+		return "map"
+	default:
+		return "number"
+	}
+}
+
+func contains(list []string, s string) bool {
+	for i := range list {
+		if list[i] == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// references finds the values used by templates.
+type references struct {
+	names []string
+
+	// the jar declares variables, so other values are rejected
+	strict bool
+}
+
+func newReferences(metadata *viper.Viper) references {
+	variables, _ := ParseVariables(metadata)
+	refs := references{names: valueNames(DefaultValues(metadata), ""), strict: len(variables) > 0}
+
+	for _, v := range variables {
+		refs.names = append(refs.names, v.Name)
+	}
+
+	return refs
+}
+
+// valueNames lists the dotted name of every value, including nested values.
+func valueNames(values Values, prefix string) []string {
+	var names []string
+
+	for key, value := range values {
+		names = append(names, prefix+key)
+
+		if nested, ok := value.(Values); ok {
+			names = append(names, valueNames(nested, prefix+key+".")...)
+		}
+	}
+
+	return names
+}
+
+// undeclared returns the dotted names of the values used by a template which
+// are not declared.  Only fields of the top-level data are considered, since
+// inside range and with the fields belong to something else.
+func (r references) undeclared(tmpl *template.Template) []string {
+	found := map[string]bool{}
+
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			r.walk(t.Tree.Root, true, found)
+		}
+	}
+
+	var names []string
+
+	for name := range found {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+func (r references) walk(node parse.Node, top bool, found map[string]bool) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+
+		for _, child := range n.Nodes {
+			r.walk(child, top, found)
+		}
+	case *parse.ActionNode:
+		r.walk(n.Pipe, top, found)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+
+		for _, cmd := range n.Cmds {
+			r.walk(cmd, top, found)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			r.walk(arg, top, found)
+		}
+	case *parse.ChainNode:
+		r.walk(n.Node, top, found)
+	case *parse.IfNode:
+		r.walk(n.Pipe, top, found)
+		r.walk(n.List, top, found)
+		r.walk(n.ElseList, top, found)
+	case *parse.RangeNode:
+		r.walk(n.Pipe, top, found)
+		r.walk(n.List, false, found)
+		r.walk(n.ElseList, top, found)
+	case *parse.WithNode:
+		r.walk(n.Pipe, top, found)
+		r.walk(n.List, false, found)
+		r.walk(n.ElseList, top, found)
+	case *parse.TemplateNode:
+		r.walk(n.Pipe, top, found)
+	case *parse.FieldNode:
+		if top {
+			r.check(n.Ident, found)
+		}
+	case *parse.VariableNode:
+		if len(n.Ident) > 1 && n.Ident[0] == "$" {
+			r.check(n.Ident[1:], found)
+		}
+	}
+}
+
+func (r references) check(fields []string, found map[string]bool) {
+	if fields[0] == "Values" {
+		fields = fields[1:]
+	} else if contains(templateFields, fields[0]) {
+		return
+	}
+
+	if len(fields) == 0 {
+		return
+	}
+
+	name := strings.Join(fields, ".")
+
+	for _, declared := range r.names {
+		if declared == name || strings.HasPrefix(declared, name+".") || strings.HasPrefix(name, declared+".") {
+			return
+		}
+	}
+
+	found[name] = true
+}
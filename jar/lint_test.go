@@ -0,0 +1,167 @@
+// Copyright © 2018 Steve Huff <steve.huff@asics.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package jar
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+// lintJar returns a jar in memory with a metadata file and the given files.
+func lintJar(t *testing.T, yaml string, files map[string]string) *MasonJar {
+	j := memJar(t, "example", yaml, "/metadata.yaml")
+
+	for name, content := range files {
+		if err := afero.WriteFile(j.fs, name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return j
+}
+
+func formatFindings(findings []Finding) []string {
+	var formatted []string
+
+	for _, f := range findings {
+		formatted = append(formatted, fmt.Sprintf("%v %v %v: %v", f.Severity, f.Check, f.Path, f.Message))
+	}
+
+	return formatted
+}
+
+func TestLint(t *testing.T) {
+	// each finding must start with the one wanted, since the errors of
+	// text/template vary between versions of Go
+	tests := []struct {
+		name  string
+		yaml  string
+		files map[string]string
+		want  []string
+	}{
+		{
+			name:  "a clean jar",
+			yaml:  "prefix: x-\nvariables:\n  - name: service\ntemplates:\n  main.go: {}\n",
+			files: map[string]string{"/main.go": "package {{ .service }}\n"},
+		},
+		{
+			name: "unknown keys",
+			yaml: "prefix: x-\nprefx: y-\npreconditions:\n  on_mars: true\nhooks:\n  post_close: []\n",
+			want: []string{
+				"warning metadata metadata.yaml: unknown key prefx",
+				"warning hooks metadata.yaml: unknown hook stage post_close; use pre_open or post_open",
+				"warning metadata metadata.yaml: unknown precondition on_mars",
+			},
+		},
+		{
+			name: "values of the wrong kind",
+			yaml: "prefix: [x-]\ntags: go\n",
+			want: []string{
+				"error metadata metadata.yaml: prefix should be a string, not a list",
+				"error metadata metadata.yaml: tags should be a list, not a string",
+			},
+		},
+		{
+			name:  "missing templates",
+			yaml:  "prefix: x-\ntemplates:\n  main.go: {}\n  Makefile: {}\n",
+			files: map[string]string{"/main.go": "package main\n"},
+			want:  []string{"error templates metadata.yaml: templates lists makefile, which is not a file in the jar"},
+		},
+		{
+			name:  "templates which don't parse",
+			yaml:  "prefix: x-\ntemplates:\n  main.go: {}\n",
+			files: map[string]string{"/main.go": "package {{ .service\n"},
+			want:  []string{"error templates main.go: unable to parse template: template: /main.go:"},
+		},
+		{
+			name:  "undeclared variables",
+			yaml:  "prefix: x-\nvariables:\n  - name: service\ntemplates:\n  main.go: {}\n",
+			files: map[string]string{"/main.go": "package {{ .service }}\n// {{ .owner }}\n"},
+			want:  []string{"error templates main.go: template uses owner, which is not a declared variable"},
+		},
+		{
+			name:  "values without a default",
+			yaml:  "prefix: x-\nvalues:\n  service: api\ntemplates:\n  main.go: {}\n",
+			files: map[string]string{"/main.go": "package {{ .service }}\n// {{ .owner }}\n"},
+			want:  []string{"warning templates main.go: template uses owner, which has no default and must be given with --set"},
+		},
+		{
+			name: "path collisions",
+			yaml: "prefix: x-\nvariables:\n  - name: a\n    default: same\n  - name: b\n    default: same\n",
+			files: map[string]string{
+				"/{{ .a }}.go": "package a\n",
+				"/{{ .b }}.go": "package b\n",
+			},
+			want: []string{"error paths : /{{ .a }}.go and /{{ .b }}.go both render to /same.go"},
+		},
+		{
+			name: "hooks which run missing files",
+			yaml: "prefix: x-\nhooks:\n  post_open:\n    - command: [./setup.sh]\n",
+			want: []string{"error hooks metadata.yaml: post_open hook './setup.sh' runs ./setup.sh, which is not a file in the jar"},
+		},
+	}
+
+	for _, test := range tests {
+		j := lintJar(t, test.yaml, test.files)
+		got := formatFindings(Lint(j))
+		matched := len(got) == len(test.want)
+
+		for i := 0; matched && i < len(got); i++ {
+			matched = strings.HasPrefix(got[i], test.want[i])
+		}
+
+		if !matched {
+			t.Errorf("%v: got findings\n  %q\nwant\n  %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestLintSymlinks(t *testing.T) {
+	r, dir := testGitRepo(t)
+	defer os.RemoveAll(dir)
+
+	hash := commitFiles(t, r, dir, "Add a jar", map[string]string{
+		"example/metadata.yaml":    "prefix: x-\n",
+		"example/docs/README.md":   "read me",
+		"example/README.md":        "-> docs/README.md",
+		"example/docs/escape":      "-> ../../outside",
+		"example/docs/parent-file": "-> ../README.md",
+	})
+
+	jars, invalid, err := commitJars(r, &Repository{Dir: dir}, hash)
+
+	if err != nil || len(jars) != 1 || len(invalid) > 0 {
+		t.Fatalf("got jars %v and invalid jars %v: %v", jars, invalid, err)
+	}
+
+	want := []string{
+		"error symlinks docs/escape: symlink points outside the jar: ../../outside",
+	}
+
+	if got := formatFindings(Lint(jars[0])); !reflect.DeepEqual(got, want) {
+		t.Errorf("got findings\n  %q\nwant\n  %q", got, want)
+	}
+}
@@ -22,6 +22,7 @@ package jar
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
 
 	"github.com/spf13/afero"
@@ -33,6 +34,11 @@ type Jar interface {
 	Path() string
 	Prefix() string
 	Metadata() *viper.Viper
+	OwnMetadata() *viper.Viper
+	Parent() Jar
+	Repository() *Repository
+	Fs() afero.Fs
+	Files() ([]string, error)
 	ParseMetadata(string) (*viper.Viper, error)
 	Walk(filepath.WalkFunc) error
 }
@@ -41,6 +47,11 @@ type MasonJar struct {
 	name     string
 	path     string
 	metadata *viper.Viper
+	own      *viper.Viper
+	parent   *MasonJar
+	repo     *Repository
+	fs       afero.Fs
+	files    []string
 }
 
 func (j *MasonJar) Name() string {
@@ -51,17 +62,74 @@ func (j *MasonJar) Path() string {
 	return j.path
 }
 
+// Metadata returns the jar's metadata, including anything inherited from the
+// jar it extends.
 func (j *MasonJar) Metadata() *viper.Viper {
 	return j.metadata
 }
 
+// OwnMetadata returns only the metadata declared by the jar itself.
+func (j *MasonJar) OwnMetadata() *viper.Viper {
+	return j.own
+}
+
+// Parent returns the jar this jar extends, if any.
+func (j *MasonJar) Parent() Jar {
+	if j.parent == nil {
+		return nil
+	}
+
+	return j.parent
+}
+
+// Repository returns the repository the jar came from, if it is known.
+func (j *MasonJar) Repository() *Repository {
+	return j.repo
+}
+
+// Extends returns the name of the jar this jar extends.
+func (j *MasonJar) Extends() string {
+	return j.OwnMetadata().GetString("extends")
+}
+
 func (j *MasonJar) Prefix() string {
 	return j.Metadata().GetString("prefix")
 }
 
+// Fs returns a read-only filesystem rooted at the jar.
+func (j *MasonJar) Fs() afero.Fs {
+	return j.fs
+}
+
+// Files returns the path of every file in the jar, as it would be passed to
+// Walk, e.g. "/cmd/main.go".  Jars read from an index already know their
+// files, so nothing needs to be read.
+func (j *MasonJar) Files() ([]string, error) {
+	if j.files != nil {
+		var files []string
+
+		for _, f := range j.files {
+			files = append(files, "/"+f)
+		}
+
+		return files, nil
+	}
+
+	var files []string
+
+	err := j.Walk(func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			files = append(files, path)
+		}
+
+		return err
+	})
+
+	return files, err
+}
+
 func (j *MasonJar) Walk(walkFn filepath.WalkFunc) error {
-	fs := afero.NewBasePathFs(afero.NewOsFs(), j.Path())
-	afs := &afero.Afero{Fs: fs}
+	afs := &afero.Afero{Fs: j.Fs()}
 
 	err := afs.Walk("/", walkFn)
 
@@ -69,19 +137,35 @@ func (j *MasonJar) Walk(walkFn filepath.WalkFunc) error {
 }
 
 func NewJar(path string) (*MasonJar, error) {
-	j := new(MasonJar)
-	j.path = path
-
 	_, name := filepath.Split(path)
 
+	return newOsJar(name, path)
+}
+
+// newOsJar reads the jar in a directory on the local filesystem.
+func newOsJar(name string, path string) (*MasonJar, error) {
+	fs := afero.NewBasePathFs(afero.NewReadOnlyFs(afero.NewOsFs()), path)
+
+	return newJar(name, path, fs)
+}
+
+func newJar(name string, path string, fs afero.Fs) (*MasonJar, error) {
+	j := new(MasonJar)
 	j.name = name
+	j.path = path
+	j.fs = fs
 
 	metadata, err := j.ParseMetadata(MetadataFileName)
 
+	if _, ok := err.(*missingMetadataError); ok {
+		return nil, err
+	}
+
 	if err != nil {
-		return nil, fmt.Errorf("%v is not a valid Jar directory", path)
+		return nil, fmt.Errorf("invalid metadata: %v", err)
 	}
 
 	j.metadata = metadata
+	j.own = metadata
 	return j, err
 }
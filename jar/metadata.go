@@ -21,27 +21,64 @@
 package jar
 
 import (
+	"bytes"
+	"fmt"
+
+	"github.com/spf13/afero"
 	jww "github.com/spf13/jwalterweatherman"
 	"github.com/spf13/viper"
 )
 
 const MetadataFileName = "metadata"
 
+// missingMetadataError is returned when a directory has no metadata file, and
+// so isn't a jar at all.
+type missingMetadataError struct {
+	filename string
+	path     string
+}
+
+func (e *missingMetadataError) Error() string {
+	return fmt.Sprintf("no %v file found in %v", e.filename, e.path)
+}
+
 func (j *MasonJar) ParseMetadata(filename string) (*viper.Viper, error) {
-	path := j.Path()
+	jww.DEBUG.Printf("parsing metadata for jar %v (path: %v, filename: %v)", j.Name(), j.Path(), filename)
 
-	jww.DEBUG.Printf("parsing metadata for jar %v (path: %v, filename: %v)", j.Name(), path, filename)
+	data, ext, err := readMetadata(j.Fs(), j.Path(), filename)
 
-	config := viper.New()
+	if err != nil {
+		jww.DEBUG.Printf("unable to parse metadata: %v", err)
+		return nil, err
+	}
 
-	config.SetConfigName(filename)
-	config.AddConfigPath(j.Path())
+	return parseMetadata(data, ext)
+}
+
+// readMetadata reads a jar's metadata file, in whichever of the formats
+// supported by viper it was written.
+func readMetadata(fs afero.Fs, path string, filename string) ([]byte, string, error) {
+	afs := &afero.Afero{Fs: fs}
 
-	err := config.ReadInConfig()
+	// the jar may not be on the OS filesystem, so look for the file ourselves
+	for _, ext := range viper.SupportedExts {
+		data, err := afs.ReadFile(fmt.Sprintf("/%v.%v", filename, ext))
+
+		if err == nil {
+			return data, ext, nil
+		}
+	}
+
+	return nil, "", &missingMetadataError{filename, path}
+}
+
+func parseMetadata(data []byte, ext string) (*viper.Viper, error) {
+	config := viper.New()
+	config.SetConfigType(ext)
+	err := config.ReadConfig(bytes.NewReader(data))
 
 	if err != nil {
 		jww.WARN.Printf("unable to parse metadata: %v", err)
-		return nil, err
 	}
 
 	return config, err
@@ -0,0 +1,131 @@
+// Copyright © 2018 Steve Huff <steve.huff@asics.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package jar
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	jww "github.com/spf13/jwalterweatherman"
+)
+
+// RenderPath renders the template expressions in each segment of a jar path.
+func RenderPath(path string, data TemplateData) (string, error) {
+	if !strings.Contains(path, "{{") {
+		return path, nil
+	}
+
+	segments := strings.Split(path, "/")
+
+	for i := range segments {
+		segment := segments[i]
+
+		if !strings.Contains(segment, "{{") {
+			continue
+		}
+
+		tmpl, err := template.New(path).Option("missingkey=error").Parse(segment)
+
+		if err != nil {
+			return "", fmt.Errorf("unable to parse path %v: %v", path, err)
+		}
+
+		var rendered bytes.Buffer
+		err = tmpl.Execute(&rendered, data)
+
+		if err != nil {
+			return "", fmt.Errorf("unable to render path %v: %v", path, err)
+		}
+
+		switch s := strings.TrimSpace(rendered.String()); {
+		case len(s) == 0:
+			return "", fmt.Errorf("path %v renders an empty segment", path)
+		case s == "." || s == "..":
+			return "", fmt.Errorf("path %v renders a '%v' segment", path, s)
+		case strings.ContainsRune(s, '/'):
+			return "", fmt.Errorf("path %v renders a segment containing '/': %v", path, s)
+		default:
+			segments[i] = s
+		}
+	}
+
+	return strings.Join(segments, "/"), nil
+}
+
+// RenderPaths walks a jar and renders every path which will be written to
+// the destination, returning a map of source paths to destination paths.
+// Paths matching an excluded pattern are left out.  Paths which cannot be
+// rendered, or which render to the same destination, are reported together.
+func RenderPaths(j Jar, data TemplateData, excluded []string) (map[string]string, error) {
+	paths := map[string]string{}
+	sources := map[string]string{}
+	var problems []string
+
+	err := j.Walk(func(path string, info os.FileInfo, err error) error {
+		if err != nil || IsSkippable(path) {
+			return nil
+		}
+
+		if IsExcluded(path, excluded) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		dest, err := RenderPath(path, data)
+
+		if err != nil {
+			problems = append(problems, err.Error())
+			return nil
+		}
+
+		if src, ok := sources[dest]; ok {
+			problems = append(problems, fmt.Sprintf("%v and %v both render to %v", src, path, dest))
+			return nil
+		}
+
+		if dest != path {
+			jww.DEBUG.Printf("rendered path %v as %v", path, dest)
+		}
+
+		sources[dest] = path
+		paths[path] = dest
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return nil, &ValidationError{Jar: j.Name(), What: "invalid paths", Problems: problems}
+	}
+
+	return paths, nil
+}
@@ -0,0 +1,147 @@
+// Copyright © 2018 Steve Huff <steve.huff@asics.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package jar
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+// memJar returns a jar whose files are held in memory.
+func memJar(t *testing.T, name string, yaml string, files ...string) *MasonJar {
+	j := testJar(t, name, yaml)
+	j.fs = afero.NewMemMapFs()
+
+	for _, file := range files {
+		if err := afero.WriteFile(j.fs, file, []byte(file), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return j
+}
+
+func TestRenderPath(t *testing.T) {
+	data := TemplateData{"Identifier": "jarhead", "service": "api", "empty": "", "dots": "..", "slash": "a/b", "padded": " web "}
+
+	tests := []struct {
+		path    string
+		want    string
+		wantErr string
+	}{
+		{path: "/plain/file.go", want: "/plain/file.go"},
+		{path: "/cmd/{{ .Identifier }}/main.go", want: "/cmd/jarhead/main.go"},
+		{path: "/{{ .service }}-{{ .Identifier }}.tf", want: "/api-jarhead.tf"},
+		{path: "/{{ .padded }}/x", want: "/web/x"},
+		{path: "/{{ .empty }}/x", wantErr: "renders an empty segment"},
+		{path: "/{{ .dots }}/x", wantErr: "renders a '..' segment"},
+		{path: "/{{ .slash }}", wantErr: "renders a segment containing '/'"},
+		{path: "/{{ .missing }}", wantErr: "unable to render path"},
+		{path: "/{{ .service", wantErr: "unable to parse path"},
+	}
+
+	for _, test := range tests {
+		got, err := RenderPath(test.path, data)
+
+		if len(test.wantErr) > 0 {
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("RenderPath(%q): got error %v, want %q", test.path, err, test.wantErr)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("RenderPath(%q): unexpected error: %v", test.path, err)
+		} else if got != test.want {
+			t.Errorf("RenderPath(%q) = %q, want %q", test.path, got, test.want)
+		}
+	}
+}
+
+func TestRenderPaths(t *testing.T) {
+	data := TemplateData{"name": "api", "empty": ""}
+
+	tests := []struct {
+		name     string
+		files    []string
+		excluded []string
+		want     map[string]string
+		problems []string
+	}{
+		{
+			name:  "rendered paths",
+			files: []string{"/metadata.yaml", "/{{ .name }}/main.go", "/README.md"},
+			want: map[string]string{
+				"/{{ .name }}":         "/api",
+				"/{{ .name }}/main.go": "/api/main.go",
+				"/README.md":           "/README.md",
+			},
+		},
+		{
+			name:     "excluded paths are not rendered",
+			files:    []string{"/{{ .empty }}/x", "/keep"},
+			excluded: []string{"{{ .empty }}"},
+			want:     map[string]string{"/keep": "/keep"},
+		},
+		{
+			name:  "every problem is reported",
+			files: []string{"/api.go", "/{{ .name }}.go", "/{{ .empty }}/x.go"},
+			problems: []string{
+				"/api.go and /{{ .name }}.go both render to /api.go",
+				"path /{{ .empty }} renders an empty segment",
+				"path /{{ .empty }}/x.go renders an empty segment",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		j := memJar(t, "paths", "prefix: x", test.files...)
+		got, err := RenderPaths(j, data, test.excluded)
+
+		if len(test.problems) > 0 {
+			verr, ok := err.(*ValidationError)
+
+			if !ok {
+				t.Errorf("%v: expected a ValidationError, got %v", test.name, err)
+				continue
+			}
+
+			sort.Strings(test.problems)
+
+			if !reflect.DeepEqual(verr.Problems, test.problems) {
+				t.Errorf("%v: got problems %q, want %q", test.name, verr.Problems, test.problems)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", test.name, err)
+		} else if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+// Copyright © 2018 Steve Huff <steve.huff@asics.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package jar
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	jww "github.com/spf13/jwalterweatherman"
+	"github.com/spf13/viper"
+)
+
+var (
+	versionPattern       = regexp.MustCompile(`\d+(\.\d+)*`)
+	dottedVersionPattern = regexp.MustCompile(`\d+(\.\d+)+`)
+)
+
+// Preconditions are declared under "preconditions" in a jar's metadata and
+// must all hold before a jar is opened.
+type Preconditions struct {
+	Executables  []Executable `mapstructure:"executables"`
+	Env          []string     `mapstructure:"env"`
+	NotInGitRepo bool         `mapstructure:"not_in_git_repo"`
+}
+
+// Executable is a program which must be on the PATH, optionally with a
+// minimum version.  The version is read from the output of the program run
+// with VersionArgs, "--version" by default.
+type Executable struct {
+	Name        string   `mapstructure:"name"`
+	MinVersion  string   `mapstructure:"min_version"`
+	VersionArgs []string `mapstructure:"version_args"`
+}
+
+// ParsePreconditions decodes and checks the preconditions in a jar's metadata.
+func ParsePreconditions(metadata *viper.Viper) (Preconditions, error) {
+	var p Preconditions
+
+	if metadata == nil || !metadata.IsSet("preconditions") {
+		return p, nil
+	}
+
+	err := metadata.UnmarshalKey("preconditions", &p)
+
+	if err != nil {
+		return p, fmt.Errorf("unable to parse preconditions: %v", err)
+	}
+
+	var problems []string
+
+	for i := range p.Executables {
+		e := p.Executables[i]
+
+		if len(e.Name) == 0 {
+			problems = append(problems, fmt.Sprintf("executable #%v has no name", i+1))
+		}
+
+		if len(e.MinVersion) > 0 && !versionPattern.MatchString(e.MinVersion) {
+			problems = append(problems, fmt.Sprintf("executable %v has invalid min_version '%v'", e.Name, e.MinVersion))
+		}
+	}
+
+	if len(problems) > 0 {
+		return p, fmt.Errorf("invalid preconditions:\n  - %v", strings.Join(problems, "\n  - "))
+	}
+
+	return p, nil
+}
+
+// Check returns a description of every precondition which does not hold for
+// a jar opened beneath destination.  Executables are only run to check their
+// versions if runCommands is set; otherwise they only have to be on the PATH.
+func (p Preconditions) Check(destination string, runCommands bool) []string {
+	var problems []string
+
+	for i := range p.Executables {
+		if err := p.Executables[i].Check(runCommands); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	for _, name := range p.Env {
+		if len(os.Getenv(name)) == 0 {
+			problems = append(problems, fmt.Sprintf("environment variable %v is not set", name))
+		}
+	}
+
+	if p.NotInGitRepo {
+		if repo, ok := findGitRepo(destination); ok {
+			problems = append(problems, fmt.Sprintf("destination %v is inside the git repository %v", destination, repo))
+		}
+	}
+
+	return problems
+}
+
+// VersionCommand returns the command run to find the executable's version,
+// or nothing if no minimum version is required.
+func (e Executable) VersionCommand() []string {
+	if len(e.MinVersion) == 0 {
+		return nil
+	}
+
+	if len(e.VersionArgs) == 0 {
+		return []string{e.Name, "--version"}
+	}
+
+	return append([]string{e.Name}, e.VersionArgs...)
+}
+
+// Check verifies that the executable is on the PATH and, if runCommand is
+// set, new enough.
+func (e Executable) Check(runCommand bool) error {
+	path, err := exec.LookPath(e.Name)
+
+	if err != nil {
+		return fmt.Errorf("executable %v was not found on the PATH", e.Name)
+	}
+
+	command := e.VersionCommand()
+
+	if len(command) == 0 {
+		return nil
+	}
+
+	if !runCommand {
+		jww.INFO.Printf("not running '%v' to check the version of %v", strings.Join(command, " "), e.Name)
+		return nil
+	}
+
+	output, err := exec.Command(path, command[1:]...).CombinedOutput()
+
+	if err != nil {
+		return fmt.Errorf("unable to determine the version of %v: %v", e.Name, err)
+	}
+
+	// prefer something that looks like a dotted version number
+	version := dottedVersionPattern.FindString(string(output))
+
+	if len(version) == 0 {
+		version = versionPattern.FindString(string(output))
+	}
+
+	if len(version) == 0 {
+		return fmt.Errorf("unable to determine the version of %v from '%v'", e.Name, strings.TrimSpace(string(output)))
+	}
+
+	jww.DEBUG.Printf("found %v version %v", e.Name, version)
+
+	if compareVersions(version, versionPattern.FindString(e.MinVersion)) < 0 {
+		return fmt.Errorf("executable %v is version %v, but at least %v is required", e.Name, version, e.MinVersion)
+	}
+
+	return nil
+}
+
+// CheckPreconditions reports every precondition of a jar which does not hold.
+// Like hooks, the commands which check versions come from the jar, so they
+// are only run if runCommands is set, once the jar has been trusted.
+func CheckPreconditions(j Jar, destination string, runCommands bool) error {
+	p, err := ParsePreconditions(j.Metadata())
+
+	if err != nil {
+		return fmt.Errorf("jar %v: %v", j.Name(), err)
+	}
+
+	problems := p.Check(destination, runCommands)
+
+	if len(problems) > 0 {
+		return &ValidationError{Jar: j.Name(), What: "unmet preconditions", Problems: problems}
+	}
+
+	return nil
+}
+
+// compareVersions compares dotted numeric versions, returning -1, 0 or 1.
+func compareVersions(a string, b string) int {
+	as := strings.Split(a, ".")
+	bs := strings.Split(b, ".")
+
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var an, bn int
+
+		if i < len(as) {
+			an, _ = strconv.Atoi(as[i])
+		}
+
+		if i < len(bs) {
+			bn, _ = strconv.Atoi(bs[i])
+		}
+
+		switch {
+		case an < bn:
+			return -1
+		case an > bn:
+			return 1
+		}
+	}
+
+	return 0
+}
+
+// findGitRepo looks for a git repository containing path.
+func findGitRepo(path string) (string, bool) {
+	dir, err := filepath.Abs(path)
+
+	if err != nil {
+		return "", false
+	}
+
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir, true
+		}
+
+		parent := filepath.Dir(dir)
+
+		if parent == dir {
+			return "", false
+		}
+
+		dir = parent
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright © 2018 Steve Huff <steve.huff@asics.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package jar
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+var cacheKeyPattern = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// Repository is a git repository of jars.
+type Repository struct {
+	Name   string         `mapstructure:"name"`
+	URL    string         `mapstructure:"url"`
+	Ref    string         `mapstructure:"ref"`
+	Remote string         `mapstructure:"remote"`
+	Auth   RepositoryAuth `mapstructure:"auth"`
+	Dir    string         `mapstructure:"-"`
+}
+
+// ParseRepositories decodes the repositories listed under "repositories" in
+// the configuration.  Repositories are listed in order of precedence.
+func ParseRepositories(config *viper.Viper) ([]Repository, error) {
+	var repos []Repository
+
+	if !config.IsSet("repositories") {
+		return repos, nil
+	}
+
+	err := config.UnmarshalKey("repositories", &repos)
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse repositories: %v", err)
+	}
+
+	var problems []string
+	names := map[string]bool{}
+
+	for i := range repos {
+		r := &repos[i]
+
+		if len(r.Remote) == 0 {
+			r.Remote = "origin"
+		}
+
+		switch {
+		case len(r.Name) == 0:
+			problems = append(problems, fmt.Sprintf("repository #%v has no name", i+1))
+		case strings.ContainsAny(r.Name, `/\@`) || r.Name == "." || r.Name == "..":
+			problems = append(problems, fmt.Sprintf("repository name '%v' may not contain '/', '\\' or '@'", r.Name))
+		case names[r.Name]:
+			problems = append(problems, fmt.Sprintf("repository %v is listed more than once", r.Name))
+		}
+
+		names[r.Name] = true
+
+		if len(r.URL) == 0 {
+			problems = append(problems, fmt.Sprintf("repository %v has no url", r.Name))
+		}
+
+		problems = append(problems, r.Auth.validate(*r)...)
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid repositories:\n  - %v", strings.Join(problems, "\n  - "))
+	}
+
+	return repos, nil
+}
+
+// CacheKey returns the name of the directory in which the repository is
+// cached.  It is derived from the repository's URL, so that changing the URL
+// never reuses a clone of a different repository.
+func (r Repository) CacheKey() string {
+	url := strings.TrimRight(r.URL, "/")
+	sum := sha256.Sum256([]byte(url))
+
+	base := strings.TrimSuffix(path.Base(filepath.ToSlash(url)), ".git")
+	base = strings.Trim(cacheKeyPattern.ReplaceAllString(base, "-"), "-")
+
+	if len(base) == 0 {
+		return hex.EncodeToString(sum[:8])
+	}
+
+	return base + "-" + hex.EncodeToString(sum[:8])
+}
+
+// QualifiedName returns the name of a jar prefixed with the name of its
+// repository, e.g. "platform/go-service".
+func QualifiedName(j Jar) string {
+	if r := j.Repository(); r != nil && len(r.Name) > 0 {
+		return qualify(r.Name, j.Name())
+	}
+
+	return j.Name()
+}
+
+// RepositoryURL returns the URL of the repository a jar came from.
+func RepositoryURL(j Jar) string {
+	if r := j.Repository(); r != nil && len(r.URL) > 0 {
+		return r.URL
+	}
+
+	return viper.GetString("RepoUrl")
+}
+
+// Label names a repository in messages: its name, or its URL if it has no
+// name.
+func (r Repository) Label() string {
+	if len(r.Name) > 0 {
+		return r.Name
+	}
+
+	return r.URL
+}
+
+// jarRepositoryLabel returns the label of the repository a jar came from, if
+// it is known.
+func jarRepositoryLabel(j Jar) string {
+	if r := j.Repository(); r != nil {
+		return r.Label()
+	}
+
+	return ""
+}
+
+func repositoryName(j Jar) string {
+	if r := j.Repository(); r != nil {
+		return r.Name
+	}
+
+	return ""
+}
+
+func qualify(repoName string, jarName string) string {
+	if len(repoName) == 0 {
+		return jarName
+	}
+
+	return repoName + "/" + jarName
+}
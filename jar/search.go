@@ -0,0 +1,390 @@
+// Copyright © 2018 Steve Huff <steve.huff@asics.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package jar
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// SearchResult is a jar which matched a search.  Higher scores are better
+// matches.
+type SearchResult struct {
+	Summary `yaml:",inline"`
+	Score   float64       `json:"score" yaml:"score"`
+	Matches []SearchMatch `json:"matches" yaml:"matches"`
+}
+
+// SearchMatch records where a search matched one of a jar's fields.  Ranges
+// are the byte offsets of each match in Text.  Text is the whole field,
+// except for descriptions and READMEs, where it is the line containing the
+// first match.
+type SearchMatch struct {
+	Field  string   `json:"field" yaml:"field"`
+	Text   string   `json:"text" yaml:"text"`
+	Ranges [][2]int `json:"ranges" yaml:"ranges"`
+}
+
+// searchField is a part of a jar which is searched.  Matches in fields with a
+// higher weight count for more, and only names are matched by subsequence.
+type searchField struct {
+	name        string
+	weight      float64
+	text        string
+	subsequence bool
+	snippet     bool
+}
+
+// deprecatedWeight scales the score of deprecated jars, so they are ranked
+// below similar jars which aren't deprecated.
+const deprecatedWeight = 0.5
+
+// Search ranks jars by how well their name, tags, description and README
+// match every word of query.  Words may match exactly, as a prefix or part
+// of a word, with a typo or two, or, in names, as a subsequence, e.g. "lgo"
+// for "lambda-go".  Jars which don't match every word are left out.
+func Search(jars []Jar, query string) []SearchResult {
+	terms := strings.Fields(strings.ToLower(query))
+	results := []SearchResult{}
+
+	if len(terms) == 0 {
+		return results
+	}
+
+	for i := range jars {
+		summary := Summarize(jars[i])
+
+		fields := []searchField{
+			{name: "name", weight: 10, text: summary.Name, subsequence: true},
+			{name: "tags", weight: 6, text: strings.Join(summary.Tags, ", ")},
+			{name: "description", weight: 3, text: summary.Description, snippet: true},
+			{name: "readme", weight: 1, text: readme(jars[i]), snippet: true},
+		}
+
+		ranges := make([][][2]int, len(fields))
+		score := 0.0
+
+		for _, term := range terms {
+			best := 0.0
+
+			for k, f := range fields {
+				s, r := matchTerm(term, f.text, f.subsequence)
+
+				if s > 0 {
+					ranges[k] = append(ranges[k], r...)
+				}
+
+				if s*f.weight > best {
+					best = s * f.weight
+				}
+			}
+
+			if best == 0 {
+				score = 0
+				break
+			}
+
+			score += best
+		}
+
+		if score == 0 {
+			continue
+		}
+
+		if summary.Deprecated {
+			score *= deprecatedWeight
+		}
+
+		result := SearchResult{Summary: summary, Score: score, Matches: []SearchMatch{}}
+
+		for k, f := range fields {
+			if len(ranges[k]) == 0 {
+				continue
+			}
+
+			text, r := f.text, mergeRanges(ranges[k])
+
+			if f.snippet {
+				text, r = snippet(text, r)
+			}
+
+			result.Matches = append(result.Matches, SearchMatch{Field: f.name, Text: text, Ranges: r})
+		}
+
+		results = append(results, result)
+	}
+
+	sort.SliceStable(results, func(a, b int) bool {
+		if results[a].Score != results[b].Score {
+			return results[a].Score > results[b].Score
+		}
+
+		return results[a].Name < results[b].Name
+	})
+
+	return results
+}
+
+// matchTerm scores how well a lower case term matches text, from 0 for no
+// match to 1 for a whole word, and returns where it matched.
+func matchTerm(term string, text string, subsequence bool) (float64, [][2]int) {
+	lower, offsets := lowerCase(text)
+	score, ranges := matchLower(term, lower, subsequence)
+
+	for i := range ranges {
+		ranges[i] = [2]int{offsets[ranges[i][0]], offsets[ranges[i][1]]}
+	}
+
+	return score, ranges
+}
+
+// lowerCase returns text in lower case, along with the offset in text of
+// each byte of the result, and of its end.  Lowering some characters
+// changes how many bytes they take, so offsets into the result can't be
+// used with text directly.
+func lowerCase(text string) (string, []int) {
+	var lower bytes.Buffer
+	offsets := make([]int, 0, len(text)+1)
+
+	for i, c := range text {
+		n, _ := lower.WriteRune(unicode.ToLower(c))
+
+		for k := 0; k < n; k++ {
+			offsets = append(offsets, i)
+		}
+	}
+
+	return lower.String(), append(offsets, len(text))
+}
+
+// matchLower matches term against text which is already in lower case, and
+// returns ranges in lower.
+func matchLower(term string, lower string, subsequence bool) (float64, [][2]int) {
+	score := 0.0
+	var ranges [][2]int
+
+	for offset := 0; ; {
+		i := strings.Index(lower[offset:], term)
+
+		if i < 0 {
+			break
+		}
+
+		start, end := offset+i, offset+i+len(term)
+		s := 0.75
+
+		if isWordStart(lower, start) {
+			s = 0.9
+
+			if isWordEnd(lower, end) {
+				s = 1
+			}
+		}
+
+		if s > score {
+			score = s
+		}
+
+		ranges = append(ranges, [2]int{start, end})
+		offset = end
+	}
+
+	if score > 0 {
+		return score, ranges
+	}
+
+	// allow a typo for every four characters
+	if maxDistance := utf8.RuneCountInString(term) / 4; maxDistance > 0 {
+		for _, w := range words(lower) {
+			d := distance(term, lower[w[0]:w[1]])
+
+			if d <= maxDistance {
+				s := 0.6 * (1 - float64(d)/float64(utf8.RuneCountInString(term)))
+
+				if s > score {
+					score = s
+				}
+
+				ranges = append(ranges, w)
+			}
+		}
+	}
+
+	if score > 0 || !subsequence {
+		return score, ranges
+	}
+
+	// every character of term, in order
+	offset := 0
+
+	for _, c := range term {
+		i := strings.IndexRune(lower[offset:], c)
+
+		if i < 0 {
+			return 0, nil
+		}
+
+		start := offset + i
+		offset = start + utf8.RuneLen(c)
+		ranges = append(ranges, [2]int{start, offset})
+	}
+
+	return 0.3, ranges
+}
+
+func isWordChar(c rune) bool {
+	return unicode.IsLetter(c) || unicode.IsDigit(c)
+}
+
+func isWordStart(s string, i int) bool {
+	if i == 0 {
+		return true
+	}
+
+	c, _ := utf8.DecodeLastRuneInString(s[:i])
+	return !isWordChar(c)
+}
+
+func isWordEnd(s string, i int) bool {
+	if i == len(s) {
+		return true
+	}
+
+	c, _ := utf8.DecodeRuneInString(s[i:])
+	return !isWordChar(c)
+}
+
+// words returns the start and end of each word in s.
+func words(s string) [][2]int {
+	var found [][2]int
+	start := -1
+
+	for i, c := range s {
+		switch {
+		case isWordChar(c) && start < 0:
+			start = i
+		case !isWordChar(c) && start >= 0:
+			found = append(found, [2]int{start, i})
+			start = -1
+		}
+	}
+
+	if start >= 0 {
+		found = append(found, [2]int{start, len(s)})
+	}
+
+	return found
+}
+
+// distance returns the number of insertions, deletions, substitutions and
+// transpositions of adjacent characters needed to turn a into b.
+func distance(a string, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	d := make([][]int, len(ra)+1)
+
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+
+	for j := range d[0] {
+		d[0][j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+
+			d[i][j] = minInt(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d[i][j] = minInt(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+
+	return d[len(ra)][len(rb)]
+}
+
+func minInt(values ...int) int {
+	m := values[0]
+
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+
+	return m
+}
+
+// mergeRanges sorts ranges and joins those which overlap.
+func mergeRanges(ranges [][2]int) [][2]int {
+	sort.Slice(ranges, func(a, b int) bool {
+		return ranges[a][0] < ranges[b][0]
+	})
+
+	var merged [][2]int
+
+	for _, r := range ranges {
+		if n := len(merged); n > 0 && r[0] <= merged[n-1][1] {
+			if r[1] > merged[n-1][1] {
+				merged[n-1][1] = r[1]
+			}
+
+			continue
+		}
+
+		merged = append(merged, r)
+	}
+
+	return merged
+}
+
+// snippet returns the line of text containing the first of ranges, and the
+// ranges which fall within that line, relative to it.
+func snippet(text string, ranges [][2]int) (string, [][2]int) {
+	start := strings.LastIndex(text[:ranges[0][0]], "\n") + 1
+	end := strings.Index(text[start:], "\n")
+
+	if end < 0 {
+		end = len(text)
+	} else {
+		end += start
+	}
+
+	var within [][2]int
+
+	for _, r := range ranges {
+		if r[0] >= start && r[1] <= end {
+			within = append(within, [2]int{r[0] - start, r[1] - start})
+		}
+	}
+
+	return text[start:end], within
+}
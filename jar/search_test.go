@@ -0,0 +1,109 @@
+// Copyright © 2018 Steve Huff <steve.huff@asics.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package jar
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatchTerm(t *testing.T) {
+	tests := []struct {
+		term        string
+		text        string
+		subsequence bool
+		score       float64
+		ranges      [][2]int
+	}{
+		{term: "lambda", text: "lambda-go", score: 1, ranges: [][2]int{{0, 6}}},
+		{term: "lam", text: "lambda-go", score: 0.9, ranges: [][2]int{{0, 3}}},
+		{term: "go", text: "Lambda-Go", score: 1, ranges: [][2]int{{7, 9}}},
+		{term: "bda", text: "lambda-go", score: 0.75, ranges: [][2]int{{3, 6}}},
+		{term: "kubernets", text: "Deploys to kubernetes", score: 0.6 * (1 - 1.0/9), ranges: [][2]int{{11, 21}}},
+		{term: "lgo", text: "lambda-go", subsequence: true, score: 0.3, ranges: [][2]int{{0, 1}, {7, 8}, {8, 9}}},
+		{term: "lgo", text: "lambda-go"},
+		{term: "rust", text: "lambda-go", subsequence: true},
+		// lowering these changes their length in bytes
+		{term: "istanbul", text: "Deploys to \u0130stanbul", score: 1, ranges: [][2]int{{11, 20}}},
+		{term: "kelvin", text: "\u212aelvin scale", score: 1, ranges: [][2]int{{0, 8}}},
+		{term: "scale", text: "\u212aelvin scale", score: 1, ranges: [][2]int{{9, 14}}},
+	}
+
+	for _, test := range tests {
+		score, ranges := matchTerm(test.term, test.text, test.subsequence)
+
+		if score != test.score || !reflect.DeepEqual(ranges, test.ranges) {
+			t.Errorf("matchTerm(%q, %q): got %v %v, want %v %v", test.term, test.text, score, ranges, test.score, test.ranges)
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	jars := []Jar{
+		memJar(t, "api", "description: Serves requests behind a lambda"),
+		memJar(t, "lambda-go", "description: A Go function"),
+		memJar(t, "old-lambda", "deprecated: Use lambda-go instead"),
+		memJar(t, "worker", "tags: [lambda, queue]"),
+		memJar(t, "web", "description: A web site", "/README.md"),
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{query: "lambda", want: []string{"lambda-go", "worker", "old-lambda", "api"}},
+		{query: "LAMBDA go", want: []string{"lambda-go"}},
+		{query: "lgo", want: []string{"lambda-go"}},
+		{query: "readme", want: []string{"web"}},
+		{query: "rust", want: []string{}},
+		{query: " ", want: []string{}},
+	}
+
+	for _, test := range tests {
+		got := []string{}
+
+		for _, result := range Search(jars, test.query) {
+			got = append(got, result.Name)
+		}
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Search(%q): got %v, want %v", test.query, got, test.want)
+		}
+	}
+}
+
+func TestSearchMatches(t *testing.T) {
+	j := memJar(t, "lambda-go", "description: |\n  A Go function.\n  Runs on AWS Lambda.\n")
+	results := Search([]Jar{j}, "lambda")
+
+	if len(results) != 1 {
+		t.Fatalf("got %v results", len(results))
+	}
+
+	want := []SearchMatch{
+		{Field: "name", Text: "lambda-go", Ranges: [][2]int{{0, 6}}},
+		{Field: "description", Text: "Runs on AWS Lambda.", Ranges: [][2]int{{12, 18}}},
+	}
+
+	if !reflect.DeepEqual(results[0].Matches, want) {
+		t.Errorf("got matches %+v, want %+v", results[0].Matches, want)
+	}
+}
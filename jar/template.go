@@ -21,5 +21,98 @@
 package jar
 
 import (
-// "text/template"
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"text/template"
+
+	"github.com/spf13/afero"
+	jww "github.com/spf13/jwalterweatherman"
+	"github.com/spf13/viper"
 )
+
+// TemplateData is the data model exposed to templated files in a jar.
+type TemplateData map[string]interface{}
+
+// NewTemplateData builds the data model for the current jar.  Values are
+// available as .Values and, unless they collide with a built-in field, at the
+// top level.
+func NewTemplateData(metadata *viper.Viper, values Values) TemplateData {
+	data := TemplateData{}
+
+	for key, value := range values {
+		data[key] = value
+	}
+
+	data["Jar"] = viper.GetString("CurrentJarName")
+	data["Identifier"] = viper.GetString("JarIdentifier")
+	data["Destination"] = viper.GetString("JarDestination")
+	data["DestRoot"] = viper.GetString("DestRoot")
+	data["Prefix"] = metadata.GetString("prefix")
+	data["Metadata"] = metadata.AllSettings()
+	data["Values"] = values
+
+	return data
+}
+
+// IsTemplate reports whether the file at path is listed under "templates" in
+// a jar's metadata, rather than being copied verbatim.
+func IsTemplate(path string, metadata *viper.Viper) bool {
+	filename := filepath.Base(path)
+	template_spec := fmt.Sprintf("%s.%s", "templates", filename)
+
+	if !metadata.IsSet(template_spec) {
+		jww.DEBUG.Printf("no template specification for %v", template_spec)
+		return false
+	}
+
+	jww.INFO.Printf("found template specification for %v", template_spec)
+	return true
+}
+
+func ProcessTemplate(path string, destPath string, srcFs afero.Fs, destFs afero.Fs, data TemplateData) error {
+	jww.DEBUG.Printf("rendering template %v to %v", path, destPath)
+
+	sfs := &afero.Afero{Fs: srcFs}
+	text, err := sfs.ReadFile(path)
+
+	if err != nil {
+		jww.ERROR.Println(err)
+		return err
+	}
+
+	tmpl, err := template.New(path).Option("missingkey=error").Parse(string(text))
+
+	if err != nil {
+		jww.ERROR.Printf("error parsing template %v: %v", path, err)
+		return err
+	}
+
+	var rendered bytes.Buffer
+	err = tmpl.Execute(&rendered, data)
+
+	if err != nil {
+		jww.ERROR.Printf("error rendering template %v: %v", path, err)
+		return err
+	}
+
+	fileInfo, err := srcFs.Stat(path)
+
+	if err != nil {
+		jww.ERROR.Println(err)
+		return err
+	}
+
+	dfs := &afero.Afero{Fs: destFs}
+	err = dfs.WriteFile(destPath, rendered.Bytes(), fileInfo.Mode())
+
+	if err != nil {
+		jww.ERROR.Println(err)
+		return err
+	}
+
+	jww.DEBUG.Printf("rendered %v, %v bytes", destPath, rendered.Len())
+
+	// WriteFile only applies the mode to newly created files
+	return destFs.Chmod(destPath, fileInfo.Mode())
+}
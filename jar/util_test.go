@@ -0,0 +1,144 @@
+// Copyright © 2018 Steve Huff <steve.huff@asics.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package jar
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+func TestFindJars(t *testing.T) {
+	aws := &Repository{Name: "aws"}
+	platform := &Repository{Name: "platform"}
+	product := &Repository{Name: "product"}
+
+	inRepo := func(repo *Repository, name string) *MasonJar {
+		j := testJar(t, name, "prefix: x-")
+		j.repo = repo
+		return j
+	}
+
+	jars := []Jar{
+		inRepo(aws, "lambda-go"),
+		inRepo(platform, "aws/lambda-go"),
+		inRepo(platform, "base"),
+		inRepo(product, "base"),
+		inRepo(product, "k8s/job"),
+	}
+
+	tests := []struct {
+		target  string
+		want    string
+		wantErr string
+	}{
+		{target: "lambda-go", want: "aws/lambda-go"},
+		{target: "platform/aws/lambda-go", want: "platform/aws/lambda-go"},
+		{target: "aws/lambda-go", wantErr: "aws/lambda-go is ambiguous: it matches lambda-go in repository aws and aws/lambda-go in repository platform; use lambda-go or platform/aws/lambda-go instead"},
+		{target: "base", want: "platform/base"},
+		{target: "product/base", want: "product/base"},
+		{target: "k8s/job", want: "product/k8s/job"},
+		{target: "missing"},
+	}
+
+	for _, test := range tests {
+		found, missing, err := FindJars([]string{test.target}, jars, nil)
+
+		if len(test.wantErr) > 0 {
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("FindJars(%q): got error %v, want %q", test.target, err, test.wantErr)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("FindJars(%q): %v", test.target, err)
+			continue
+		}
+
+		if len(test.want) == 0 {
+			if len(found) > 0 || len(missing) != 1 {
+				t.Errorf("FindJars(%q): got %v, want it to be missing", test.target, found)
+			}
+
+			continue
+		}
+
+		if len(found) != 1 || QualifiedName(found[0]) != test.want {
+			t.Errorf("FindJars(%q): got %v, want %v", test.target, found, test.want)
+		}
+	}
+}
+
+func TestCopyFileSymlink(t *testing.T) {
+	r, dir := testGitRepo(t)
+	defer os.RemoveAll(dir)
+
+	hash := commitFiles(t, r, dir, "add files", map[string]string{
+		"docs/README.md": "read me",
+		"README.md":      "-> docs/README.md",
+	})
+
+	commit, err := r.CommitObject(hash)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tree, err := commit.Tree()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	destDir, err := ioutil.TempDir("", "masonjar-dest")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(destDir)
+
+	srcFs := NewTreeFs(tree, commit.Committer.When)
+	destFs := afero.NewBasePathFs(afero.NewOsFs(), destDir)
+
+	// copying the link a second time replaces it
+	for _, path := range []string{"/README.md", "/README.md"} {
+		if err = CopyFile(path, path, srcFs, destFs); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	target, err := os.Readlink(filepath.Join(destDir, "README.md"))
+
+	if err != nil {
+		t.Fatalf("expected a symlink: %v", err)
+	}
+
+	if target != "docs/README.md" {
+		t.Errorf("got target %v, want docs/README.md", target)
+	}
+}
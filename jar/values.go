@@ -0,0 +1,311 @@
+// Copyright © 2018 Steve Huff <steve.huff@asics.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package jar
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	jww "github.com/spf13/jwalterweatherman"
+	"github.com/spf13/viper"
+)
+
+// Values holds the variables supplied to a jar's templates.  Keys are
+// case-insensitive and stored in lower case, like the rest of the metadata.
+type Values map[string]interface{}
+
+// ParseSetValues parses a list of key=value assignments, as passed to
+// "masonjar open --set".  Dotted keys create nested values.
+func ParseSetValues(assignments []string) (Values, error) {
+	values := Values{}
+
+	for i := range assignments {
+		assignment := assignments[i]
+		parts := strings.SplitN(assignment, "=", 2)
+
+		if len(parts) != 2 || len(strings.TrimSpace(parts[0])) == 0 {
+			return nil, fmt.Errorf("invalid value '%v', expected key=value", assignment)
+		}
+
+		err := values.Set(parts[0], parseValue(parts[1]))
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return values, nil
+}
+
+// Set assigns value to the (possibly dotted) key, creating intermediate maps
+// as needed.
+func (v Values) Set(key string, value interface{}) error {
+	path := strings.Split(strings.ToLower(strings.TrimSpace(key)), ".")
+	m := v
+
+	for i := range path[:len(path)-1] {
+		if len(path[i]) == 0 {
+			return fmt.Errorf("invalid key '%v'", key)
+		}
+
+		next, ok := m[path[i]].(Values)
+
+		if !ok {
+			if _, exists := m[path[i]]; exists {
+				return fmt.Errorf("key '%v' conflicts with existing value at '%v'", key, strings.Join(path[:i+1], "."))
+			}
+
+			next = Values{}
+			m[path[i]] = next
+		}
+
+		m = next
+	}
+
+	last := path[len(path)-1]
+
+	if len(last) == 0 {
+		return fmt.Errorf("invalid key '%v'", key)
+	}
+
+	m[last] = value
+	return nil
+}
+
+// Get returns the value at the (possibly dotted) key.
+func (v Values) Get(key string) (interface{}, bool) {
+	path := strings.Split(strings.ToLower(key), ".")
+	var value interface{} = v
+
+	for i := range path {
+		m, ok := value.(Values)
+
+		if !ok {
+			return nil, false
+		}
+
+		value, ok = m[path[i]]
+
+		if !ok {
+			return nil, false
+		}
+	}
+
+	return value, true
+}
+
+// Merge deep-merges src over v, with values from src taking precedence.
+func (v Values) Merge(src Values) Values {
+	for key, value := range src {
+		srcMap, srcIsMap := value.(Values)
+		destMap, destIsMap := v[key].(Values)
+
+		if srcIsMap && destIsMap {
+			v[key] = destMap.Merge(srcMap)
+		} else if srcIsMap {
+			v[key] = Values{}.Merge(srcMap)
+		} else {
+			v[key] = value
+		}
+	}
+
+	return v
+}
+
+// ReadValuesFiles reads values from YAML, JSON or TOML files, with values
+// from later files taking precedence.
+func ReadValuesFiles(filenames []string) (Values, error) {
+	values := Values{}
+
+	for i := range filenames {
+		jww.DEBUG.Printf("reading values from %v", filenames[i])
+
+		config := viper.New()
+		config.SetConfigFile(filenames[i])
+
+		err := config.ReadInConfig()
+
+		if err != nil {
+			return nil, fmt.Errorf("unable to read values from %v: %v", filenames[i], err)
+		}
+
+		values.Merge(normalizeValue(config.AllSettings()).(Values))
+	}
+
+	return values, nil
+}
+
+// WriteValuesFile writes values to filename, in a format determined by its
+// extension.
+func WriteValuesFile(filename string, values Values) error {
+	jww.DEBUG.Printf("writing values to %v", filename)
+
+	config := viper.New()
+
+	for key, value := range values.toMap() {
+		config.Set(key, value)
+	}
+
+	return config.WriteConfigAs(filename)
+}
+
+// WithoutSecrets returns a copy of values without any secret variables.
+func (v Values) WithoutSecrets(variables []Variable) Values {
+	values := Values{}.Merge(v)
+
+	for i := range variables {
+		if !variables[i].Secret {
+			continue
+		}
+
+		path := strings.Split(variables[i].Name, ".")
+		parent := values
+
+		if len(path) > 1 {
+			value, _ := values.Get(strings.Join(path[:len(path)-1], "."))
+			parent, _ = value.(Values)
+		}
+
+		if parent != nil {
+			delete(parent, path[len(path)-1])
+		}
+	}
+
+	return values
+}
+
+// toMap converts values into plain maps, as expected by viper and the
+// config encoders.
+func (v Values) toMap() map[string]interface{} {
+	m := map[string]interface{}{}
+
+	for key, value := range v {
+		if nested, ok := value.(Values); ok {
+			m[key] = nested.toMap()
+		} else {
+			m[key] = value
+		}
+	}
+
+	return m
+}
+
+// DefaultValues returns the default values declared under "values" in a
+// jar's metadata.
+func DefaultValues(metadata *viper.Viper) Values {
+	if metadata == nil || !metadata.IsSet("values") {
+		return Values{}
+	}
+
+	defaults, ok := normalizeValue(metadata.Get("values")).(Values)
+
+	if !ok {
+		jww.WARN.Printf("ignoring metadata values, expected a map but found %T", metadata.Get("values"))
+		return Values{}
+	}
+
+	return defaults
+}
+
+// normalizeValue converts the map types produced by the various config
+// decoders into Values, and whole numbers decoded from JSON into ints,
+// recursively.
+func normalizeValue(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case Values:
+		for k, v := range typed {
+			typed[k] = normalizeValue(v)
+		}
+		return typed
+	case map[string]interface{}:
+		m := Values{}
+		for k, v := range typed {
+			m[strings.ToLower(k)] = normalizeValue(v)
+		}
+		return m
+	case map[interface{}]interface{}:
+		m := Values{}
+		for k, v := range typed {
+			m[strings.ToLower(fmt.Sprint(k))] = normalizeValue(v)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(typed))
+		for i := range typed {
+			l[i] = normalizeValue(typed[i])
+		}
+		return l
+	case float64:
+		// JSON has no integers, so whole numbers are decoded as floats
+		if typed == math.Trunc(typed) && math.Abs(typed) <= 1<<53 {
+			return int(typed)
+		}
+		return typed
+	default:
+		return value
+	}
+}
+
+// parseValue converts the string form of a value into a bool, number or list
+// where possible.  Quoted values are always strings.
+func parseValue(raw string) interface{} {
+	s := strings.TrimSpace(raw)
+
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+
+	if len(s) >= 2 && s[0] == '[' && s[len(s)-1] == ']' {
+		inner := strings.TrimSpace(s[1 : len(s)-1])
+		list := []interface{}{}
+
+		if len(inner) == 0 {
+			return list
+		}
+
+		items := strings.Split(inner, ",")
+
+		for i := range items {
+			list = append(list, parseValue(items[i]))
+		}
+
+		return list
+	}
+
+	switch s {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+
+	if i, err := strconv.Atoi(s); err == nil {
+		return i
+	}
+
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+
+	return s
+}
@@ -0,0 +1,129 @@
+// Copyright © 2018 Steve Huff <steve.huff@asics.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package jar
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseValue(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want interface{}
+	}{
+		{"hello", "hello"},
+		{" padded ", "padded"},
+		{"true", true},
+		{"false", false},
+		{"True", "True"},
+		{"3", 3},
+		{"-12", -12},
+		{"2.5", 2.5},
+		{`"3"`, "3"},
+		{"'true'", "true"},
+		{`"unterminated`, `"unterminated`},
+		{"[]", []interface{}{}},
+		{"[a, 2, false]", []interface{}{"a", 2, false}},
+		{"", ""},
+	}
+
+	for _, test := range tests {
+		if got := parseValue(test.raw); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parseValue(%q) = %#v, want %#v", test.raw, got, test.want)
+		}
+	}
+}
+
+func TestParseSetValues(t *testing.T) {
+	tests := []struct {
+		name        string
+		assignments []string
+		want        Values
+		wantErr     bool
+	}{
+		{
+			name:        "typed values",
+			assignments: []string{"owner=platform", "replicas=3", "enabled=true", "regions=[us-east-1, eu-west-1]"},
+			want: Values{
+				"owner":    "platform",
+				"replicas": 3,
+				"enabled":  true,
+				"regions":  []interface{}{"us-east-1", "eu-west-1"},
+			},
+		},
+		{
+			name:        "dotted keys create nested values",
+			assignments: []string{"datadog.enabled=true", "datadog.site=eu", "a.b.c=1"},
+			want: Values{
+				"datadog": Values{"enabled": true, "site": "eu"},
+				"a":       Values{"b": Values{"c": 1}},
+			},
+		},
+		{
+			name:        "keys are case-insensitive",
+			assignments: []string{"Owner=a", "OWNER=b"},
+			want:        Values{"owner": "b"},
+		},
+		{
+			name:        "values may contain =",
+			assignments: []string{"query=a=b"},
+			want:        Values{"query": "a=b"},
+		},
+		{name: "missing =", assignments: []string{"owner"}, wantErr: true},
+		{name: "empty key", assignments: []string{"=x"}, wantErr: true},
+		{name: "empty segment", assignments: []string{"a..b=x"}, wantErr: true},
+		{name: "trailing dot", assignments: []string{"a.=x"}, wantErr: true},
+		{name: "nested under a scalar", assignments: []string{"a=1", "a.b=2"}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		got, err := ParseSetValues(test.assignments)
+
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%v: expected an error, got %#v", test.name, got)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", test.name, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: got %#v, want %#v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestValuesMerge(t *testing.T) {
+	dest := Values{"a": 1, "nested": Values{"x": 1, "y": 2}}
+	src := Values{"b": 2, "nested": Values{"y": 3}}
+
+	want := Values{"a": 1, "b": 2, "nested": Values{"x": 1, "y": 3}}
+
+	if got := dest.Merge(src); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+}
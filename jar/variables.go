@@ -0,0 +1,432 @@
+// Copyright © 2018 Steve Huff <steve.huff@asics.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package jar
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	jww "github.com/spf13/jwalterweatherman"
+	"github.com/spf13/viper"
+)
+
+const (
+	VariableTypeString = "string"
+	VariableTypeInt    = "int"
+	VariableTypeBool   = "bool"
+	VariableTypeChoice = "choice"
+	VariableTypeList   = "list"
+)
+
+// Variable is an input declared under "variables" in a jar's metadata.
+type Variable struct {
+	Name        string        `mapstructure:"name" json:"name" yaml:"name"`
+	Type        string        `mapstructure:"type" json:"type" yaml:"type"`
+	Description string        `mapstructure:"description" json:"description,omitempty" yaml:"description,omitempty"`
+	Default     interface{}   `mapstructure:"default" json:"default,omitempty" yaml:"default,omitempty"`
+	Required    bool          `mapstructure:"required" json:"required" yaml:"required"`
+	Pattern     string        `mapstructure:"pattern" json:"pattern,omitempty" yaml:"pattern,omitempty"`
+	Min         *int          `mapstructure:"min" json:"min,omitempty" yaml:"min,omitempty"`
+	Max         *int          `mapstructure:"max" json:"max,omitempty" yaml:"max,omitempty"`
+	Choices     []interface{} `mapstructure:"choices" json:"choices,omitempty" yaml:"choices,omitempty"`
+	Secret      bool          `mapstructure:"secret" json:"secret" yaml:"secret"`
+}
+
+// PromptFunc asks the user for the value of a variable which was not
+// supplied.  defaultValue is nil if the variable has no default.  The
+// returned value must already be valid for the variable.
+type PromptFunc func(v Variable, defaultValue interface{}) (interface{}, error)
+
+// ValidationError collects every problem found while validating a jar's
+// metadata or values, so they can be reported at once.
+type ValidationError struct {
+	Jar      string
+	What     string
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%v for jar %v:\n  - %v", e.What, e.Jar, strings.Join(e.Problems, "\n  - "))
+}
+
+// ParseVariables decodes and checks the variable declarations in a jar's
+// metadata, in the order they were declared.
+func ParseVariables(metadata *viper.Viper) ([]Variable, error) {
+	var variables []Variable
+
+	if metadata == nil || !metadata.IsSet("variables") {
+		return variables, nil
+	}
+
+	err := metadata.UnmarshalKey("variables", &variables)
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse variables: %v", err)
+	}
+
+	var problems []string
+	seen := map[string]bool{}
+
+	for i := range variables {
+		v := &variables[i]
+		v.Name = strings.ToLower(strings.TrimSpace(v.Name))
+		v.Default = normalizeValue(v.Default)
+
+		for c := range v.Choices {
+			v.Choices[c] = normalizeValue(v.Choices[c])
+		}
+
+		if len(v.Type) == 0 {
+			v.Type = VariableTypeString
+		}
+
+		if len(v.Name) == 0 {
+			problems = append(problems, fmt.Sprintf("variable #%v has no name", i+1))
+			continue
+		}
+
+		if seen[v.Name] {
+			problems = append(problems, fmt.Sprintf("variable %v is declared more than once", v.Name))
+		}
+
+		seen[v.Name] = true
+
+		switch v.Type {
+		case VariableTypeString, VariableTypeInt, VariableTypeBool, VariableTypeList:
+		case VariableTypeChoice:
+			if len(v.Choices) == 0 {
+				problems = append(problems, fmt.Sprintf("variable %v is a choice but declares no choices", v.Name))
+			}
+		default:
+			problems = append(problems, fmt.Sprintf("variable %v has unknown type '%v'", v.Name, v.Type))
+		}
+
+		if len(v.Pattern) > 0 {
+			if _, err := regexp.Compile(v.Pattern); err != nil {
+				problems = append(problems, fmt.Sprintf("variable %v has invalid pattern: %v", v.Name, err))
+			}
+		}
+
+		if v.Min != nil && v.Max != nil && *v.Min > *v.Max {
+			problems = append(problems, fmt.Sprintf("variable %v has min greater than max", v.Name))
+		}
+
+		if v.Default != nil {
+			if _, err := v.Validate(v.Default); err != nil {
+				problems = append(problems, fmt.Sprintf("variable %v has invalid default: %v", v.Name, err))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return variables, fmt.Errorf("invalid variables:\n  - %v", strings.Join(problems, "\n  - "))
+	}
+
+	return variables, nil
+}
+
+// Validate converts value to the variable's type and checks it against the
+// variable's constraints, returning the converted value.
+func (v Variable) Validate(value interface{}) (interface{}, error) {
+	switch v.Type {
+	case VariableTypeInt:
+		i, err := toInt(value)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if v.Min != nil && i < *v.Min {
+			return nil, fmt.Errorf("%v is less than the minimum of %v", i, *v.Min)
+		}
+
+		if v.Max != nil && i > *v.Max {
+			return nil, fmt.Errorf("%v is greater than the maximum of %v", i, *v.Max)
+		}
+
+		return i, nil
+	case VariableTypeBool:
+		switch typed := value.(type) {
+		case bool:
+			return typed, nil
+		case string:
+			switch strings.ToLower(strings.TrimSpace(typed)) {
+			case "yes", "y":
+				return true, nil
+			case "no", "n":
+				return false, nil
+			}
+
+			b, err := strconv.ParseBool(strings.TrimSpace(typed))
+
+			if err != nil {
+				return nil, fmt.Errorf("'%v' is not a boolean", typed)
+			}
+
+			return b, nil
+		default:
+			return nil, fmt.Errorf("'%v' is not a boolean", value)
+		}
+	case VariableTypeChoice:
+		s, err := toString(value)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range v.Choices {
+			if fmt.Sprint(v.Choices[i]) == s {
+				return s, nil
+			}
+		}
+
+		return nil, fmt.Errorf("'%v' is not one of %v", s, v.Choices)
+	case VariableTypeList:
+		var list []interface{}
+
+		switch typed := value.(type) {
+		case []interface{}:
+			list = typed
+		case []string:
+			for i := range typed {
+				list = append(list, typed[i])
+			}
+		case string:
+			list = parseValue(fmt.Sprintf("[%v]", strings.Trim(typed, "[]"))).([]interface{})
+		default:
+			return nil, fmt.Errorf("'%v' is not a list", value)
+		}
+
+		if v.Min != nil && len(list) < *v.Min {
+			return nil, fmt.Errorf("list has fewer than %v items", *v.Min)
+		}
+
+		if v.Max != nil && len(list) > *v.Max {
+			return nil, fmt.Errorf("list has more than %v items", *v.Max)
+		}
+
+		for i := range list {
+			if err := v.matchPattern(fmt.Sprint(list[i])); err != nil {
+				return nil, err
+			}
+		}
+
+		return list, nil
+	default:
+		s, err := toString(value)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return s, v.matchPattern(s)
+	}
+}
+
+// ZeroValue is used for optional variables that have no default and were not
+// supplied, so that templates can always refer to them.
+func (v Variable) ZeroValue() interface{} {
+	switch v.Type {
+	case VariableTypeInt:
+		return 0
+	case VariableTypeBool:
+		return false
+	case VariableTypeList:
+		return []interface{}{}
+	default:
+		return ""
+	}
+}
+
+func (v Variable) matchPattern(s string) error {
+	if len(v.Pattern) == 0 {
+		return nil
+	}
+
+	matched, err := regexp.MatchString(v.Pattern, s)
+
+	if err != nil {
+		return err
+	}
+
+	if !matched {
+		return fmt.Errorf("'%v' does not match pattern %v", s, v.Pattern)
+	}
+
+	return nil
+}
+
+// ResolveValues merges supplied values over the defaults from a jar's
+// metadata and validates the result against the jar's declared variables.
+// If prompt is not nil it is used to ask for variables which were not
+// supplied.  Every problem is reported in a single ValidationError.
+func ResolveValues(j Jar, supplied Values, prompt PromptFunc) (Values, error) {
+	metadata := j.Metadata()
+	variables, err := ParseVariables(metadata)
+
+	if err != nil {
+		return nil, fmt.Errorf("jar %v: %v", j.Name(), err)
+	}
+
+	values := DefaultValues(metadata)
+
+	for i := range variables {
+		v := variables[i]
+
+		if _, ok := values.Get(v.Name); !ok && v.Default != nil {
+			values.Set(v.Name, normalizeValue(v.Default))
+		}
+	}
+
+	values.Merge(supplied)
+
+	if len(variables) == 0 {
+		return values, nil
+	}
+
+	if prompt != nil {
+		for i := range variables {
+			v := variables[i]
+
+			if _, ok := supplied.Get(v.Name); ok {
+				continue
+			}
+
+			current, _ := values.Get(v.Name)
+			answer, err := prompt(v, current)
+
+			if err != nil {
+				return nil, fmt.Errorf("unable to read a value for %v: %v", v.Name, err)
+			}
+
+			values.Set(v.Name, answer)
+		}
+	}
+
+	var problems []string
+
+	for i := range variables {
+		v := variables[i]
+		value, ok := values.Get(v.Name)
+
+		if !ok {
+			if v.Required {
+				problems = append(problems, fmt.Sprintf("%v is required but no value was supplied", v.Name))
+			} else {
+				values.Set(v.Name, v.ZeroValue())
+			}
+
+			continue
+		}
+
+		converted, err := v.Validate(value)
+
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%v: %v", v.Name, err))
+			continue
+		}
+
+		values.Set(v.Name, converted)
+	}
+
+	undeclared := undeclaredKeys(variables, supplied, "")
+	sort.Strings(undeclared)
+
+	defaults := DefaultValues(metadata)
+
+	for _, key := range undeclared {
+		// values with a default in the metadata may always be overridden
+		if _, ok := defaults.Get(key); ok {
+			continue
+		}
+
+		problems = append(problems, fmt.Sprintf("%v is not a variable of this jar", key))
+	}
+
+	if len(problems) > 0 {
+		return nil, &ValidationError{Jar: j.Name(), What: "invalid values", Problems: problems}
+	}
+
+	jww.DEBUG.Printf("resolved %v values for jar %v", len(values), j.Name())
+	return values, nil
+}
+
+// undeclaredKeys lists the supplied keys which neither name a variable nor
+// contain one.
+func undeclaredKeys(variables []Variable, supplied Values, prefix string) []string {
+	var keys []string
+
+	for key, value := range supplied {
+		name := prefix + key
+		declared := false
+
+		for i := range variables {
+			if variables[i].Name == name {
+				declared = true
+				break
+			}
+
+			if nested, ok := value.(Values); ok && strings.HasPrefix(variables[i].Name, name+".") {
+				keys = append(keys, undeclaredKeys(variables, nested, name+".")...)
+				declared = true
+				break
+			}
+		}
+
+		if !declared {
+			keys = append(keys, name)
+		}
+	}
+
+	return keys
+}
+
+func toString(value interface{}) (string, error) {
+	switch value.(type) {
+	case Values, []interface{}:
+		return "", fmt.Errorf("'%v' is not a scalar value", value)
+	default:
+		return fmt.Sprint(value), nil
+	}
+}
+
+func toInt(value interface{}) (int, error) {
+	switch typed := value.(type) {
+	case int:
+		return typed, nil
+	case int64:
+		return int(typed), nil
+	case float64:
+		if typed == math.Trunc(typed) {
+			return int(typed), nil
+		}
+	case string:
+		if i, err := strconv.Atoi(strings.TrimSpace(typed)); err == nil {
+			return i, nil
+		}
+	}
+
+	return 0, fmt.Errorf("'%v' is not an integer", value)
+}
@@ -0,0 +1,239 @@
+// Copyright © 2018 Steve Huff <steve.huff@asics.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package jar
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// testMetadata parses YAML metadata for a test.
+func testMetadata(t *testing.T, yaml string) *viper.Viper {
+	metadata, err := parseMetadata([]byte(yaml), "yaml")
+
+	if err != nil {
+		t.Fatalf("unable to parse metadata: %v", err)
+	}
+
+	return metadata
+}
+
+func testJar(t *testing.T, name string, yaml string) *MasonJar {
+	metadata := testMetadata(t, yaml)
+	return &MasonJar{name: name, path: name, metadata: metadata, own: metadata}
+}
+
+func intPointer(i int) *int {
+	return &i
+}
+
+func TestVariableValidate(t *testing.T) {
+	tests := []struct {
+		variable Variable
+		value    interface{}
+		want     interface{}
+		wantErr  bool
+	}{
+		{Variable{Type: VariableTypeString}, "x", "x", false},
+		{Variable{Type: VariableTypeString}, 3, "3", false},
+		{Variable{Type: VariableTypeString, Pattern: "^[a-z]+$"}, "abc", "abc", false},
+		{Variable{Type: VariableTypeString, Pattern: "^[a-z]+$"}, "ABC", nil, true},
+		{Variable{Type: VariableTypeInt}, "7", 7, false},
+		{Variable{Type: VariableTypeInt}, "seven", nil, true},
+		{Variable{Type: VariableTypeInt, Min: intPointer(1), Max: intPointer(5)}, 0, nil, true},
+		{Variable{Type: VariableTypeInt, Min: intPointer(1), Max: intPointer(5)}, 6, nil, true},
+		{Variable{Type: VariableTypeInt, Min: intPointer(1), Max: intPointer(5)}, 5, 5, false},
+		{Variable{Type: VariableTypeBool}, "yes", true, false},
+		{Variable{Type: VariableTypeBool}, "n", false, false},
+		{Variable{Type: VariableTypeBool}, "false", false, false},
+		{Variable{Type: VariableTypeBool}, "maybe", nil, true},
+		{Variable{Type: VariableTypeChoice, Choices: []interface{}{"web", "worker"}}, "web", "web", false},
+		{Variable{Type: VariableTypeChoice, Choices: []interface{}{"web", "worker"}}, "cron", nil, true},
+		{Variable{Type: VariableTypeList}, "a, b", []interface{}{"a", "b"}, false},
+		{Variable{Type: VariableTypeList}, []interface{}{"a"}, []interface{}{"a"}, false},
+		{Variable{Type: VariableTypeList, Max: intPointer(1)}, "a, b", nil, true},
+		{Variable{Type: VariableTypeList, Pattern: "^[a-z]$"}, "a, bc", nil, true},
+	}
+
+	for _, test := range tests {
+		got, err := test.variable.Validate(test.value)
+
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%+v.Validate(%#v): expected an error, got %#v", test.variable, test.value, got)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%+v.Validate(%#v): unexpected error: %v", test.variable, test.value, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%+v.Validate(%#v) = %#v, want %#v", test.variable, test.value, got, test.want)
+		}
+	}
+}
+
+func TestParseVariablesReportsEveryProblem(t *testing.T) {
+	metadata := testMetadata(t, `
+variables:
+  - name: Service_Name
+  - type: string
+  - name: service_name
+  - name: tier
+    type: choice
+  - name: colour
+    type: colour
+  - name: code
+    pattern: "["
+  - name: replicas
+    type: int
+    min: 5
+    max: 1
+  - name: port
+    type: int
+    default: eighty
+`)
+
+	variables, err := ParseVariables(metadata)
+
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+
+	if variables[0].Name != "service_name" || variables[0].Type != VariableTypeString {
+		t.Errorf("expected names to be lower cased and types to default to string, got %+v", variables[0])
+	}
+
+	for _, problem := range []string{
+		"variable #2 has no name",
+		"variable service_name is declared more than once",
+		"variable tier is a choice but declares no choices",
+		"variable colour has unknown type 'colour'",
+		"variable code has invalid pattern",
+		"variable replicas has min greater than max",
+		"variable port has invalid default",
+	} {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("expected %q in:\n%v", problem, err)
+		}
+	}
+}
+
+func TestResolveValues(t *testing.T) {
+	j := testJar(t, "service", `
+values:
+  owner: platform
+variables:
+  - name: service_name
+    required: true
+  - name: replicas
+    type: int
+    default: 2
+    max: 5
+  - name: enabled
+    type: bool
+  - name: datadog.site
+    type: choice
+    choices: [us, eu]
+    default: us
+`)
+
+	tests := []struct {
+		name     string
+		supplied Values
+		want     Values
+		problems []string
+	}{
+		{
+			name:     "defaults and zero values",
+			supplied: Values{"service_name": "api"},
+			want: Values{
+				"owner":        "platform",
+				"service_name": "api",
+				"replicas":     2,
+				"enabled":      false,
+				"datadog":      Values{"site": "us"},
+			},
+		},
+		{
+			name:     "supplied values are converted",
+			supplied: Values{"service_name": "api", "replicas": "4", "enabled": "yes", "owner": "payments", "datadog": Values{"site": "eu"}},
+			want: Values{
+				"owner":        "payments",
+				"service_name": "api",
+				"replicas":     4,
+				"enabled":      true,
+				"datadog":      Values{"site": "eu"},
+			},
+		},
+		{
+			name:     "every problem is reported at once",
+			supplied: Values{"replicas": 9, "enabled": "maybe", "datadog": Values{"site": "ap", "key": "x"}, "colour": "blue"},
+			problems: []string{
+				"service_name is required but no value was supplied",
+				"replicas: 9 is greater than the maximum of 5",
+				"enabled: 'maybe' is not a boolean",
+				"datadog.site: 'ap' is not one of [us eu]",
+				"colour is not a variable of this jar",
+				"datadog.key is not a variable of this jar",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		got, err := ResolveValues(j, test.supplied, nil)
+
+		if len(test.problems) > 0 {
+			verr, ok := err.(*ValidationError)
+
+			if !ok {
+				t.Errorf("%v: expected a ValidationError, got %v", test.name, err)
+				continue
+			}
+
+			sort.Strings(verr.Problems)
+			sort.Strings(test.problems)
+
+			if !reflect.DeepEqual(verr.Problems, test.problems) {
+				t.Errorf("%v: got problems\n%v\nwant\n%v", test.name, strings.Join(verr.Problems, "\n"), strings.Join(test.problems, "\n"))
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", test.name, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: got %#v, want %#v", test.name, got, test.want)
+		}
+	}
+}